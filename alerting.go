@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"github.com/nu7hatch/gouuid"
+	"html"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"time"
+)
+
+const (
+	RuleAverageBelow       = "average-below"
+	RuleVeryUnhappyAbove   = "very-unhappy-above"
+	RuleBaselineDrop       = "baseline-drop"
+	RuleParticipationBelow = "participation-below"
+
+	AlertFiring   = "firing"
+	AlertResolved = "resolved"
+
+	ChannelMail    = "mail"
+	ChannelWebhook = "webhook"
+)
+
+type (
+	AlertRule struct {
+		Id        string  `json:"id" storm:"id"`
+		Name      string  `json:"name"`
+		Kind      string  `json:"kind"`
+		Window    int     `json:"window"`
+		Baseline  int     `json:"baseline"`
+		Threshold float64 `json:"threshold"`
+		Channel   string  `json:"channel"`
+		Target    string  `json:"target"`
+		Secret    string  `json:"secret,omitempty"`
+	}
+
+	Alert struct {
+		RuleId     string    `json:"rule" storm:"id"`
+		State      string    `json:"state"`
+		Value      float64   `json:"value"`
+		DateString string    `json:"date"`
+		FiredAt    time.Time `json:"fired-at"`
+		ResolvedAt time.Time `json:"resolved-at"`
+	}
+
+	AlertNotification struct {
+		Rule  AlertRule `json:"rule"`
+		Alert Alert     `json:"alert"`
+	}
+
+	moodWindow struct {
+		Votes       int
+		Weight      float64
+		Sum         float64
+		VeryUnhappy float64
+		Invited     int
+	}
+)
+
+func (rule *AlertRule) validate() error {
+	switch rule.Kind {
+	case RuleAverageBelow, RuleVeryUnhappyAbove, RuleBaselineDrop, RuleParticipationBelow:
+	default:
+		return fmt.Errorf("unknown rule kind '%s'", rule.Kind)
+	}
+
+	switch rule.Channel {
+	case ChannelMail, ChannelWebhook:
+	default:
+		return fmt.Errorf("unknown notification channel '%s'", rule.Channel)
+	}
+
+	if rule.Target == "" {
+		return fmt.Errorf("rule needs a notification target")
+	}
+
+	if rule.Window <= 0 {
+		rule.Window = 7
+	}
+
+	if rule.Kind == RuleBaselineDrop && rule.Baseline <= 0 {
+		rule.Baseline = 28
+	}
+
+	return nil
+}
+
+func (window moodWindow) average() (float64, bool) {
+	if !isPublishable(window.Votes) || window.Votes == 0 {
+		return 0, false
+	}
+	return window.Sum / window.Weight, true
+}
+
+func (window moodWindow) veryUnhappyShare() (float64, bool) {
+	if !isPublishable(window.Votes) || window.Votes == 0 {
+		return 0, false
+	}
+	return window.VeryUnhappy / window.Weight, true
+}
+
+func (window moodWindow) participation() (float64, bool) {
+	if window.Invited == 0 {
+		return 0, false
+	}
+	return float64(window.Votes) / float64(window.Invited), true
+}
+
+func collectWindow(history []DailyMoods, from time.Time, to time.Time) (window moodWindow) {
+	days := int(math.Floor(to.Sub(from).Hours()/24 + 0.5))
+
+	for _, dailyMoods := range history {
+		date := dailyMoods.Date()
+
+		if date.After(from) && !date.After(to) {
+			window.Votes += dailyMoods.Total()
+			window.Weight += dailyMoods.TotalWeight(days)
+			window.Sum += dailyMoods.WeightedSum(days)
+			window.VeryUnhappy += dailyMoods.WeightOf(dailyMoods.Scale.Lowest(), days)
+			window.Invited += dailyMoods.Invited
+		}
+	}
+
+	return window
+}
+
+func (rule *AlertRule) evaluate(history []DailyMoods, closed time.Time) (value float64, firing bool, known bool) {
+	current := collectWindow(history, closed.AddDate(0, 0, -rule.Window), closed)
+
+	switch rule.Kind {
+	case RuleAverageBelow:
+		value, known = current.average()
+		return value, value < rule.Threshold, known
+	case RuleVeryUnhappyAbove:
+		value, known = current.veryUnhappyShare()
+		return value, value > rule.Threshold, known
+	case RuleParticipationBelow:
+		value, known = current.participation()
+		return value, value < rule.Threshold, known
+	case RuleBaselineDrop:
+		baselineEnd := closed.AddDate(0, 0, -rule.Window)
+		baseline := collectWindow(history, baselineEnd.AddDate(0, 0, -rule.Baseline), baselineEnd)
+		currentAverage, currentKnown := current.average()
+		baselineAverage, baselineKnown := baseline.average()
+		value = baselineAverage - currentAverage
+		return value, value > rule.Threshold, currentKnown && baselineKnown
+	}
+
+	return 0, false, false
+}
+
+func getSortedDailyMoods(moods MoodRepository) (history []DailyMoods, databaseError error) {
+	history, databaseError = getAllDailyMoods(moods)
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].Date().Before(history[j].Date())
+	})
+
+	return history, databaseError
+}
+
+func closeDay(database *storm.DB, repositories Repositories) func() {
+	return func() {
+		history, databaseError := getSortedDailyMoods(repositories.Moods)
+
+		if databaseError != nil {
+			log.Printf("%s", databaseError)
+			return
+		}
+
+		if len(history) == 0 {
+			return
+		}
+
+		closed := history[len(history)-1]
+		log.Println("Closing day " + closed.DateString + "!")
+
+		evaluateAlerts(database, repositories, history, closed)
+		publishEvent(database, repositories.Clock, EventDayClosed, DayClosedEvent{closed.DateString, closed.Invited, closed.Paused, closed.Total()})
+	}
+}
+
+func evaluateAlerts(database *storm.DB, repositories Repositories, history []DailyMoods, closed DailyMoods) {
+	var rules []AlertRule
+
+	if databaseError := database.All(&rules); databaseError != nil {
+		log.Printf("%s", databaseError)
+		return
+	}
+
+	for _, rule := range rules {
+		value, firing, known := rule.evaluate(history, closed.Date())
+
+		if !known {
+			continue
+		}
+
+		alert := Alert{RuleId: rule.Id}
+		databaseError := database.One("RuleId", rule.Id, &alert)
+
+		if databaseError != nil && databaseError != storm.ErrNotFound {
+			log.Printf("%s", databaseError)
+			continue
+		}
+
+		wasFiring := alert.State == AlertFiring
+		alert.Value = value
+		alert.DateString = closed.DateString
+
+		if firing && !wasFiring {
+			alert.State = AlertFiring
+			alert.FiredAt = repositories.Clock.Now()
+		} else if !firing && wasFiring {
+			alert.State = AlertResolved
+			alert.ResolvedAt = repositories.Clock.Now()
+		}
+
+		if databaseError = database.Save(&alert); databaseError != nil {
+			log.Printf("%s", databaseError)
+			continue
+		}
+
+		if firing != wasFiring {
+			if rule.Channel == ChannelWebhook && rule.Secret == "" {
+				rule.Secret = createWebhookSecret()
+				log.Printf("Alert rule '%s' had no signing secret, recreate it with a known secret to verify its notifications.", rule.Name)
+
+				if databaseError = database.Save(&rule); databaseError != nil {
+					log.Printf("%s", databaseError)
+					continue
+				}
+			}
+
+			notifyAlert(repositories, rule, alert)
+		}
+	}
+}
+
+func notifyAlert(repositories Repositories, rule AlertRule, alert Alert) {
+	log.Printf("Alert '%s' is %s with value %.2f!", rule.Name, alert.State, alert.Value)
+
+	switch rule.Channel {
+	case ChannelMail:
+		if mailError := repositories.Mailer.Send(MailMessage{To: rule.Target, Subject: getAlertSubject(rule, alert), Html: getAlertHtmlText(rule, alert)}); mailError != nil {
+			log.Printf("%s", mailError)
+		}
+	case ChannelWebhook:
+		notification := AlertNotification{rule, alert}
+		notification.Rule.Secret = ""
+		sendWebhook(rule.Target, rule.Secret, "alert."+alert.State, notification, repositories.Clock.Now())
+	}
+}
+
+func getAlertSubject(rule AlertRule, alert Alert) string {
+	return fmt.Sprintf("[%s] %s", translate(DefaultLocale, "alert."+alert.State), rule.Name)
+}
+
+func getAlertHtmlText(rule AlertRule, alert Alert) string {
+	date, _ := time.Parse(dateLayout, alert.DateString)
+	text := translate(DefaultLocale, "alert.text", rule.Name, rule.Kind, rule.Threshold, translate(DefaultLocale, "alert."+alert.State), formatLongDate(DefaultLocale, date), alert.Value)
+
+	return `<html lang="` + DefaultLocale + `">
+	<head><meta charset="utf-8"></head>
+	<body>
+	<h1>` + html.EscapeString(rule.Name) + `</h1>
+	<p>` + html.EscapeString(text) + `</p>
+	</body>
+	</html>`
+}
+
+func sendWebhook(url string, secret string, eventType string, payload interface{}, now time.Time) {
+	body, jsonError := json.Marshal(payload)
+
+	if jsonError != nil {
+		log.Printf("%s", jsonError)
+		return
+	}
+
+	id, _ := uuid.NewV4()
+	response, responseError := postSignedWebhook(url, secret, eventType, id.String(), string(body), now)
+
+	if responseError != nil {
+		log.Printf("%s", responseError)
+		return
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		log.Printf("Webhook %s answered with status %d", url, response.StatusCode)
+	}
+}
+
+func getAlerts(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		var alerts []Alert
+
+		if databaseError := database.All(&alerts); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, alerts)
+		}
+	})
+}
+
+func getAlertRules(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		var rules []AlertRule
+
+		if databaseError := database.All(&rules); databaseError != nil {
+			return databaseError
+		}
+
+		for index := range rules {
+			rules[index].Secret = ""
+		}
+
+		return context.JSON(http.StatusOK, rules)
+	})
+}
+
+func postAlertRule(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		rule := new(AlertRule)
+
+		if jsonError := context.Bind(rule); jsonError != nil {
+			return jsonError
+		}
+
+		if validationError := rule.validate(); validationError != nil {
+			return context.String(http.StatusBadRequest, validationError.Error())
+		}
+
+		id, _ := uuid.NewV4()
+		rule.Id = id.String()
+
+		if rule.Channel == ChannelWebhook && rule.Secret == "" {
+			rule.Secret = createWebhookSecret()
+		}
+
+		if databaseError := database.Save(rule); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusCreated, rule)
+		}
+	})
+}
+
+func deleteAlertRule(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		rule := new(AlertRule)
+
+		if databaseError := database.One("Id", id, rule); databaseError != nil {
+			return context.String(http.StatusNotFound, "Alert rule with id '"+id+"' not found!")
+		}
+
+		if databaseError := database.Remove(rule); databaseError != nil {
+			return databaseError
+		}
+
+		database.Remove(&Alert{RuleId: id})
+
+		return context.NoContent(http.StatusNoContent)
+	})
+}
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"log"
+	"math"
+	"math/rand"
+	"time"
+
+	"github.com/asdine/storm"
+)
+
+const (
+	PeriodDay   = "day"
+	PeriodWeek  = "week"
+	PeriodMonth = "month"
+
+	SuppressionMerge    = "merge"
+	SuppressionSuppress = "suppress"
+)
+
+type (
+	PublishedMoods struct {
+		DailyMoods
+		Period       string  `json:"period"`
+		Until        string  `json:"until"`
+		WeightedMean float64 `json:"weighted-mean,omitempty"`
+		weights      map[int]float64
+	}
+
+	moodBucket struct {
+		key    string
+		period string
+		days   []DailyMoods
+	}
+)
+
+var MinimumResponses int = getEnvInt("MUT_MIN_RESPONSES", 5)
+var SuppressionMode string = getEnvString("MUT_SUPPRESSION_MODE", SuppressionMerge)
+var NoiseEpsilon float64 = getEnvFloat("MUT_NOISE_EPSILON", 0)
+var NoiseSecret string = getEnvString("MUT_NOISE_SECRET", "")
+
+func init() {
+	if SuppressionMode != SuppressionMerge && SuppressionMode != SuppressionSuppress {
+		log.Printf("Unknown suppression mode '%s', falling back to '%s'.", SuppressionMode, SuppressionMerge)
+		SuppressionMode = SuppressionMerge
+	}
+}
+
+func loadNoiseSecret(database *storm.DB) error {
+	if NoiseSecret != "" {
+		return nil
+	}
+
+	databaseError := database.Get(settingsBucket, noiseSecretKey, &NoiseSecret)
+
+	if databaseError == storm.ErrNotFound {
+		NoiseSecret = createWebhookSecret()
+		return database.Set(settingsBucket, noiseSecretKey, NoiseSecret)
+	}
+
+	return databaseError
+}
+
+func isPublishable(responses int) bool {
+	return responses >= MinimumResponses
+}
+
+func publishMoods(history []DailyMoods) (published []PublishedMoods) {
+	var weeks []*moodBucket
+	var months []*moodBucket
+
+	for _, dailyMoods := range history {
+		if isPublishable(dailyMoods.Total()) {
+			published = append(published, newPublishedMoods(PeriodDay, []DailyMoods{dailyMoods}))
+		} else if SuppressionMode == SuppressionMerge {
+			year, week := dailyMoods.Date().ISOWeek()
+			weeks = addToBucket(weeks, fmt.Sprintf("%s/%d/%d", dailyMoods.Scale.Name, year, week), PeriodWeek, dailyMoods)
+		}
+	}
+
+	for _, week := range weeks {
+		if isPublishable(totalOf(week.days)) {
+			published = append(published, newPublishedMoods(PeriodWeek, week.days))
+		} else {
+			for _, dailyMoods := range week.days {
+				months = addToBucket(months, dailyMoods.Scale.Name+"/"+dailyMoods.Date().Format("01-2006"), PeriodMonth, dailyMoods)
+			}
+		}
+	}
+
+	for _, month := range months {
+		if isPublishable(totalOf(month.days)) {
+			published = append(published, newPublishedMoods(PeriodMonth, month.days))
+		}
+	}
+
+	if NoiseEpsilon > 0 {
+		for index := range published {
+			addNoise(&published[index])
+		}
+	}
+
+	return published
+}
+
+func addToBucket(buckets []*moodBucket, key string, period string, dailyMoods DailyMoods) []*moodBucket {
+	for _, bucket := range buckets {
+		if bucket.key == key {
+			bucket.days = append(bucket.days, dailyMoods)
+			return buckets
+		}
+	}
+
+	return append(buckets, &moodBucket{key, period, []DailyMoods{dailyMoods}})
+}
+
+func totalOf(days []DailyMoods) (total int) {
+	for _, dailyMoods := range days {
+		total += dailyMoods.Total()
+	}
+	return total
+}
+
+func newPublishedMoods(period string, days []DailyMoods) (published PublishedMoods) {
+	published.Period = period
+	published.DateString = days[0].DateString
+	published.Until = days[len(days)-1].DateString
+	published.Scale = days[0].Scale
+	published.Counts = map[int]int{}
+	published.weights = map[int]float64{}
+	window := periodDays(period, days[0].Date())
+
+	for _, dailyMoods := range days {
+		for value, count := range dailyMoods.Counts {
+			published.Counts[value] += count
+			published.weights[value] += dailyMoods.WeightOf(value, window)
+		}
+		published.Invited += dailyMoods.Invited
+		published.Paused += dailyMoods.Paused
+	}
+
+	published.updateWeightedMean()
+	return published
+}
+
+func (published *PublishedMoods) updateWeightedMean() {
+	weight, sum := 0.0, 0.0
+
+	for value, valueWeight := range published.weights {
+		weight += valueWeight
+		sum += float64(value) * valueWeight
+	}
+
+	published.WeightedMean = 0
+
+	if weight > 0 {
+		published.WeightedMean = sum / weight
+	}
+}
+
+func periodDays(period string, date time.Time) int {
+	switch period {
+	case PeriodWeek:
+		return 7
+	case PeriodMonth:
+		return endOfMonth(date).Day()
+	default:
+		return 1
+	}
+}
+
+func addNoise(published *PublishedMoods) {
+	for _, point := range published.Scale.Points {
+		count := published.Counts[point.Value]
+		noisy := noisyCount(count, published.noiseSource(fmt.Sprintf("%d", point.Value)))
+
+		if count > 0 {
+			published.weights[point.Value] *= float64(noisy) / float64(count)
+		} else {
+			published.weights[point.Value] = float64(noisy)
+		}
+		published.Counts[point.Value] = noisy
+	}
+
+	published.Invited = noisyCount(published.Invited, published.noiseSource("invited"))
+	published.Paused = noisyCount(published.Paused, published.noiseSource("paused"))
+	published.updateWeightedMean()
+}
+
+func (published *PublishedMoods) noiseSource(field string) *rand.Rand {
+	mac := hmac.New(sha256.New, []byte(NoiseSecret))
+	mac.Write([]byte(published.Scale.Name + "/" + published.Period + "/" + published.DateString + "/" + published.Until + "/" + field))
+	return rand.New(rand.NewSource(int64(binary.BigEndian.Uint64(mac.Sum(nil)))))
+}
+
+func noisyCount(count int, source *rand.Rand) int {
+	noisy := int(math.Floor(float64(count) + laplaceNoise(1/NoiseEpsilon, source) + 0.5))
+
+	if noisy < 0 {
+		return 0
+	}
+	return noisy
+}
+
+func laplaceNoise(scale float64, source *rand.Rand) float64 {
+	uniform := source.Float64() - 0.5
+
+	if uniform < 0 {
+		return scale * math.Log(1+2*uniform)
+	}
+	return -scale * math.Log(1-2*uniform)
+}
@@ -0,0 +1,55 @@
+package main
+
+import (
+	"math"
+	"testing"
+)
+
+func TestNoisedMeanFollowsPublishedCounts(t *testing.T) {
+	epsilon := NoiseEpsilon
+	NoiseEpsilon = 0.5
+	defer func() { NoiseEpsilon = epsilon }()
+
+	dailyMoods := DailyMoods{DateString: "01-06-2026", Scale: LegacyScale, Invited: 20}
+
+	for voter := 0; voter < 10; voter++ {
+		if addError := dailyMoods.AddMood("4", 1); addError != nil {
+			t.Fatal(addError)
+		}
+	}
+
+	for run := 0; run < 20; run++ {
+		published := publishMoods([]DailyMoods{dailyMoods})[0]
+		total, sum := 0, 0
+
+		for value, count := range published.Counts {
+			total += count
+			sum += value * count
+		}
+
+		if total > 0 {
+			expect(t, math.Abs(published.WeightedMean-float64(sum)/float64(total)) < 1e-9, "mean %f does not match the noised counts %v", published.WeightedMean, published.Counts)
+		}
+	}
+}
+
+func TestNoiseIsStablePerBucket(t *testing.T) {
+	epsilon := NoiseEpsilon
+	NoiseEpsilon = 0.1
+	defer func() { NoiseEpsilon = epsilon }()
+
+	dailyMoods := DailyMoods{DateString: "01-06-2026", Scale: LegacyScale, Invited: 20}
+
+	for voter := 0; voter < 10; voter++ {
+		if addError := dailyMoods.AddMood("4", 1); addError != nil {
+			t.Fatal(addError)
+		}
+	}
+
+	first := publishMoods([]DailyMoods{dailyMoods})[0]
+
+	for run := 0; run < 20; run++ {
+		published := publishMoods([]DailyMoods{dailyMoods})[0]
+		expect(t, published.Counts[4] == first.Counts[4] && published.Invited == first.Invited, "repeated publications of a bucket must not draw fresh noise, got %v and %v", published.Counts, first.Counts)
+	}
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"crypto/subtle"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"net/http"
+	"os"
+)
+
+var AdminToken string = os.Getenv("MUT_ADMIN_TOKEN")
+
+func adminAuth(database *storm.DB) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(context echo.Context) error {
+			authorization := context.Request().Header().Get("Authorization")
+
+			if isOrganizationToken(database, authorization) {
+				return next(context)
+			}
+
+			if AdminToken == "" {
+				return context.String(http.StatusForbidden, "Admin routes are disabled!")
+			}
+
+			expected := []byte("Bearer " + AdminToken)
+			given := []byte(authorization)
+
+			if subtle.ConstantTimeCompare(expected, given) != 1 {
+				return context.String(http.StatusUnauthorized, "Invalid admin token!")
+			}
+
+			return next(context)
+		}
+	}
+}
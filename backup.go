@@ -0,0 +1,268 @@
+package main
+
+import (
+	"compress/gzip"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/boltdb/bolt"
+	"github.com/labstack/echo"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const snapshotPrefix = "app-mut-"
+
+var BackupDirectory string = os.Getenv("MUT_BACKUP_DIR")
+var BackupSchedule string = getEnvString("MUT_BACKUP_SCHEDULE", "0 0 2 * * *")
+var BackupKeep int = getEnvInt("MUT_BACKUP_KEEP", 7)
+var BackupCompress bool = getEnvString("MUT_BACKUP_GZIP", "true") == "true"
+
+var requiredBuckets = []string{"Subscriber", "FeedbackIdentifier", "DailyMoods"}
+
+func getBackup(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		return database.Bolt.View(func(transaction *bolt.Tx) error {
+			response := context.Response()
+			response.Header().Set("Content-Type", "application/octet-stream")
+			response.Header().Set("Content-Disposition", `attachment; filename="`+getSnapshotName(repositories.Clock.Now(), false)+`"`)
+			response.Header().Set("Content-Length", strconv.FormatInt(transaction.Size(), 10))
+			response.WriteHeader(http.StatusOK)
+
+			_, writeError := transaction.WriteTo(response)
+			return writeError
+		})
+	})
+}
+
+func getSnapshotName(at time.Time, compressed bool) string {
+	name := snapshotPrefix + at.Format("20060102-150405") + ".db"
+
+	if compressed {
+		return name + ".gz"
+	}
+	return name
+}
+
+func writeSnapshot(database *storm.DB, repositories Repositories, directory string) func() {
+	return func() {
+		path, snapshotError := createSnapshot(database, directory, BackupCompress, repositories.Clock.Now())
+
+		if snapshotError != nil {
+			log.Printf("%s", snapshotError)
+			return
+		}
+
+		log.Println("Wrote snapshot " + path + ".")
+
+		if rotateError := rotateSnapshots(directory, BackupKeep); rotateError != nil {
+			log.Printf("%s", rotateError)
+		}
+	}
+}
+
+func createSnapshot(database *storm.DB, directory string, compressed bool, now time.Time) (path string, snapshotError error) {
+	if snapshotError = os.MkdirAll(directory, 0700); snapshotError != nil {
+		return "", snapshotError
+	}
+
+	path = filepath.Join(directory, getSnapshotName(now, compressed))
+	temporary, snapshotError := ioutil.TempFile(directory, ".snapshot-")
+
+	if snapshotError != nil {
+		return "", snapshotError
+	}
+
+	defer os.Remove(temporary.Name())
+	defer temporary.Close()
+
+	var writer io.Writer = temporary
+	var compressor *gzip.Writer
+
+	if compressed {
+		compressor = gzip.NewWriter(temporary)
+		writer = compressor
+	}
+
+	snapshotError = database.Bolt.View(func(transaction *bolt.Tx) error {
+		_, writeError := transaction.WriteTo(writer)
+		return writeError
+	})
+
+	if snapshotError != nil {
+		return "", snapshotError
+	}
+
+	if compressor != nil {
+		if snapshotError = compressor.Close(); snapshotError != nil {
+			return "", snapshotError
+		}
+	}
+
+	if snapshotError = temporary.Sync(); snapshotError != nil {
+		return "", snapshotError
+	}
+
+	if snapshotError = temporary.Close(); snapshotError != nil {
+		return "", snapshotError
+	}
+
+	return path, os.Rename(temporary.Name(), path)
+}
+
+func rotateSnapshots(directory string, keep int) error {
+	files, readError := ioutil.ReadDir(directory)
+
+	if readError != nil {
+		return readError
+	}
+
+	var snapshots []string
+
+	for _, file := range files {
+		if !file.IsDir() && strings.HasPrefix(file.Name(), snapshotPrefix) {
+			snapshots = append(snapshots, file.Name())
+		}
+	}
+
+	sort.Strings(snapshots)
+
+	for len(snapshots) > keep {
+		if removeError := os.Remove(filepath.Join(directory, snapshots[0])); removeError != nil {
+			return removeError
+		}
+		snapshots = snapshots[1:]
+	}
+
+	return nil
+}
+
+func restoreSnapshot(snapshotPath string, databasePath string, clock Clock) error {
+	candidate, restoreError := extractSnapshot(snapshotPath, filepath.Dir(databasePath))
+
+	if restoreError != nil {
+		return restoreError
+	}
+
+	defer os.Remove(candidate)
+
+	if restoreError = validateSnapshot(candidate); restoreError != nil {
+		return fmt.Errorf("snapshot %s is invalid: %s", snapshotPath, restoreError)
+	}
+
+	if restoreError = ensureDatabaseUnused(databasePath); restoreError != nil {
+		return restoreError
+	}
+
+	if _, statError := os.Stat(databasePath); statError == nil {
+		previous := databasePath + ".pre-restore-" + clock.Now().Format("20060102-150405")
+
+		if restoreError = os.Rename(databasePath, previous); restoreError != nil {
+			return restoreError
+		}
+
+		log.Println("Moved current database to " + previous + ".")
+	}
+
+	if restoreError = os.Rename(candidate, databasePath); restoreError != nil {
+		return restoreError
+	}
+
+	log.Println("Restored " + snapshotPath + " to " + databasePath + ".")
+	return nil
+}
+
+func extractSnapshot(snapshotPath string, directory string) (candidate string, extractError error) {
+	source, extractError := os.Open(snapshotPath)
+
+	if extractError != nil {
+		return "", extractError
+	}
+
+	defer source.Close()
+
+	var reader io.Reader = source
+
+	if strings.HasSuffix(snapshotPath, ".gz") {
+		decompressor, gzipError := gzip.NewReader(source)
+
+		if gzipError != nil {
+			return "", gzipError
+		}
+
+		defer decompressor.Close()
+		reader = decompressor
+	}
+
+	target, extractError := ioutil.TempFile(directory, ".restore-")
+
+	if extractError != nil {
+		return "", extractError
+	}
+
+	defer target.Close()
+
+	if _, extractError = io.Copy(target, reader); extractError != nil {
+		os.Remove(target.Name())
+		return "", extractError
+	}
+
+	if extractError = target.Sync(); extractError != nil {
+		os.Remove(target.Name())
+		return "", extractError
+	}
+
+	return target.Name(), nil
+}
+
+func validateSnapshot(path string) error {
+	snapshot, openError := bolt.Open(path, 0600, &bolt.Options{ReadOnly: true, Timeout: time.Second})
+
+	if openError != nil {
+		return openError
+	}
+
+	defer snapshot.Close()
+
+	return snapshot.View(func(transaction *bolt.Tx) error {
+		var firstError error
+		for checkError := range transaction.Check() {
+			if firstError == nil {
+				firstError = checkError
+			}
+		}
+
+		if firstError != nil {
+			return firstError
+		}
+
+		for _, name := range requiredBuckets {
+			if transaction.Bucket([]byte(name)) == nil {
+				return fmt.Errorf("bucket %s is missing", name)
+			}
+		}
+
+		return nil
+	})
+}
+
+func ensureDatabaseUnused(databasePath string) error {
+	if _, statError := os.Stat(databasePath); os.IsNotExist(statError) {
+		return nil
+	}
+
+	current, openError := bolt.Open(databasePath, 0600, &bolt.Options{Timeout: time.Second})
+
+	if openError != nil {
+		return fmt.Errorf("database %s is in use, stop the service before restoring: %s", databasePath, openError)
+	}
+
+	return current.Close()
+}
@@ -0,0 +1,457 @@
+package main
+
+import (
+	"bufio"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	MailEventHardBounce  = "hard-bounce"
+	MailEventSoftBounce  = "soft-bounce"
+	MailEventComplaint   = "complaint"
+	MailEventUnsubscribe = "unsubscribe"
+	MailEventDelivered   = "delivered"
+
+	AuditSubscriberSuppress = "subscriber.suppress"
+
+	mailgunEventContextKey = "mailgun-event"
+)
+
+type (
+	MailEvent struct {
+		Recipient  string    `json:"recipient"`
+		Kind       string    `json:"kind"`
+		Reason     string    `json:"reason,omitempty"`
+		OccurredAt time.Time `json:"occurred-at"`
+	}
+
+	MailEventError struct {
+		Message string
+	}
+
+	DeliveryHealth struct {
+		SubscriberUuid string    `json:"subscriber" storm:"id"`
+		Delivered      int       `json:"delivered"`
+		HardBounces    int       `json:"hard-bounces"`
+		SoftBounces    int       `json:"soft-bounces"`
+		Complaints     int       `json:"complaints"`
+		Unsubscribes   int       `json:"unsubscribes"`
+		Flagged        bool      `json:"flagged"`
+		LastEvent      string    `json:"last-event,omitempty"`
+		LastReason     string    `json:"last-reason,omitempty"`
+		LastEventAt    time.Time `json:"last-event-at,omitempty"`
+		SuppressedAt   time.Time `json:"suppressed-at,omitempty"`
+	}
+
+	DeliveryHealthReport struct {
+		DeliveryHealth
+		Status      string `json:"status"`
+		MailsSent   int    `json:"mails-sent"`
+		MailsFailed int    `json:"mails-failed"`
+	}
+
+	mailgunEvent struct {
+		Signature struct {
+			Timestamp string `json:"timestamp"`
+			Token     string `json:"token"`
+			Signature string `json:"signature"`
+		} `json:"signature"`
+		EventData struct {
+			Event          string  `json:"event"`
+			Severity       string  `json:"severity"`
+			Recipient      string  `json:"recipient"`
+			Reason         string  `json:"reason"`
+			Timestamp      float64 `json:"timestamp"`
+			DeliveryStatus struct {
+				Code        int    `json:"code"`
+				Message     string `json:"message"`
+				Description string `json:"description"`
+			} `json:"delivery-status"`
+			UserVariables map[string]string `json:"user-variables"`
+		} `json:"event-data"`
+	}
+
+	mailgunTokens struct {
+		lock sync.Mutex
+		seen map[string]time.Time
+	}
+)
+
+var MailgunSigningKey string = os.Getenv("MUT_MAILGUN_SIGNING_KEY")
+var MailgunMaxAgeSeconds int = getEnvInt("MUT_MAILGUN_MAX_AGE_SECONDS", 300)
+var HardBounceLimit int = getEnvInt("MUT_HARD_BOUNCE_LIMIT", 1)
+var SoftBounceLimit int = getEnvInt("MUT_SOFT_BOUNCE_LIMIT", 5)
+var ComplaintLimit int = getEnvInt("MUT_COMPLAINT_LIMIT", 1)
+
+var seenMailgunTokens = &mailgunTokens{seen: map[string]time.Time{}}
+
+func (mailEventError *MailEventError) Error() string {
+	return mailEventError.Message
+}
+
+func getDeliveryHealth(node storm.Node, subscriberUuid string) (health *DeliveryHealth, databaseError error) {
+	health = &DeliveryHealth{SubscriberUuid: subscriberUuid}
+
+	if databaseError = node.One("SubscriberUuid", subscriberUuid, health); databaseError == storm.ErrNotFound {
+		return &DeliveryHealth{SubscriberUuid: subscriberUuid}, nil
+	}
+	return health, databaseError
+}
+
+func (health *DeliveryHealth) record(event MailEvent) (suppress bool) {
+	switch event.Kind {
+	case MailEventDelivered:
+		health.Delivered++
+		health.SoftBounces = 0
+		health.Flagged = false
+		return false
+	case MailEventHardBounce:
+		health.HardBounces++
+	case MailEventSoftBounce:
+		health.SoftBounces++
+	case MailEventComplaint:
+		health.Complaints++
+	case MailEventUnsubscribe:
+		health.Unsubscribes++
+	}
+
+	health.LastEvent = event.Kind
+	health.LastReason = event.Reason
+	health.LastEventAt = event.OccurredAt
+
+	if SoftBounceLimit > 0 && health.SoftBounces >= SoftBounceLimit {
+		health.Flagged = true
+	}
+
+	return health.Unsubscribes > 0 ||
+		(HardBounceLimit > 0 && health.HardBounces >= HardBounceLimit) ||
+		(ComplaintLimit > 0 && health.Complaints >= ComplaintLimit)
+}
+
+func applyMailEvent(database *storm.DB, repositories Repositories, event MailEvent) (health *DeliveryHealth, databaseError error) {
+	subscriber, databaseError := repositories.Subscribers.ByEmail(event.Recipient)
+
+	if databaseError != nil {
+		return nil, databaseError
+	}
+
+	if health, databaseError = getDeliveryHealth(database, subscriber.Uuid); databaseError != nil {
+		return nil, databaseError
+	}
+
+	if health.record(event) && subscriber.Status != SubscriberSuppressed {
+		subscriber.Status = SubscriberSuppressed
+		subscriber.UpdatedAt = repositories.Clock.Now()
+		health.SuppressedAt = subscriber.UpdatedAt
+
+		if databaseError = repositories.Subscribers.Save(subscriber); databaseError != nil {
+			return nil, databaseError
+		}
+
+		if databaseError = saveAuditEntry(database, AuditSubscriberSuppress, subscriber.Uuid, event.Kind+": "+event.Reason, repositories.Clock.Now()); databaseError != nil {
+			return nil, databaseError
+		}
+
+		publishEvent(database, repositories.Clock, EventSubscriberUnsubscribed, SubscriberEvent{subscriber.Uuid, subscriber.Team})
+	}
+
+	return health, database.Save(health)
+}
+
+func verifyMailgunSignature(timestamp string, token string, signature string, now time.Time) bool {
+	seconds, parseError := strconv.ParseInt(timestamp, 10, 64)
+
+	if MailgunSigningKey == "" || parseError != nil || token == "" {
+		return false
+	}
+
+	maximumAge := time.Duration(MailgunMaxAgeSeconds) * time.Second
+
+	if age := now.Sub(time.Unix(seconds, 0)); age > maximumAge || age < -maximumAge {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(MailgunSigningKey))
+	mac.Write([]byte(timestamp + token))
+
+	if !hmac.Equal([]byte(signature), []byte(hex.EncodeToString(mac.Sum(nil)))) {
+		return false
+	}
+
+	return seenMailgunTokens.remember(token, now.Add(2*maximumAge), now)
+}
+
+func (tokens *mailgunTokens) remember(token string, expires time.Time, now time.Time) bool {
+	tokens.lock.Lock()
+	defer tokens.lock.Unlock()
+
+	for seen, until := range tokens.seen {
+		if !until.After(now) {
+			delete(tokens.seen, seen)
+		}
+	}
+
+	if _, replayed := tokens.seen[token]; replayed {
+		return false
+	}
+
+	tokens.seen[token] = expires
+	return true
+}
+
+func (webhook *mailgunEvent) toMailEvent(now time.Time) (event MailEvent, known bool) {
+	data := webhook.EventData
+	event = MailEvent{Recipient: data.Recipient, Reason: data.Reason, OccurredAt: now}
+
+	if data.Timestamp > 0 {
+		event.OccurredAt = time.Unix(int64(data.Timestamp), 0)
+	}
+	if data.DeliveryStatus.Description != "" || data.DeliveryStatus.Message != "" {
+		event.Reason = strings.TrimSpace(fmt.Sprintf("%d %s %s", data.DeliveryStatus.Code, data.DeliveryStatus.Message, data.DeliveryStatus.Description))
+	}
+
+	switch data.Event {
+	case "failed":
+		event.Kind = MailEventSoftBounce
+		if data.Severity == "permanent" {
+			event.Kind = MailEventHardBounce
+		}
+	case "complained":
+		event.Kind = MailEventComplaint
+	case "unsubscribed":
+		event.Kind = MailEventUnsubscribe
+	case "delivered":
+		event.Kind = MailEventDelivered
+	default:
+		return event, false
+	}
+
+	return event, true
+}
+
+func parseDsn(reader io.Reader, now time.Time) (events []MailEvent, parseError error) {
+	message, parseError := mail.ReadMessage(reader)
+
+	if parseError != nil {
+		return nil, &MailEventError{"Body is not a mail message!"}
+	}
+
+	mediaType, parameters, parseError := mime.ParseMediaType(message.Header.Get("Content-Type"))
+
+	if parseError != nil || mediaType != "multipart/report" || parameters["report-type"] != "delivery-status" {
+		return nil, &MailEventError{"Message is not a delivery status notification!"}
+	}
+
+	occurredAt, dateError := message.Header.Date()
+
+	if dateError != nil {
+		occurredAt = now
+	}
+
+	parts := multipart.NewReader(message.Body, parameters["boundary"])
+
+	for {
+		part, partError := parts.NextPart()
+
+		if partError == io.EOF {
+			break
+		} else if partError != nil {
+			return nil, &MailEventError{"Report is not a valid multipart message!"}
+		}
+
+		if partType, _, _ := mime.ParseMediaType(part.Header.Get("Content-Type")); partType != "message/delivery-status" {
+			continue
+		}
+
+		return parseDeliveryStatus(part, occurredAt)
+	}
+
+	return nil, &MailEventError{"Report has no delivery status!"}
+}
+
+func parseDeliveryStatus(reader io.Reader, occurredAt time.Time) (events []MailEvent, parseError error) {
+	fields := textproto.NewReader(bufio.NewReader(reader))
+
+	if _, parseError = fields.ReadMIMEHeader(); parseError != nil && parseError != io.EOF {
+		return nil, &MailEventError{"Delivery status has malformed message fields!"}
+	}
+
+	for parseError == nil {
+		var recipient textproto.MIMEHeader
+		recipient, parseError = fields.ReadMIMEHeader()
+
+		if parseError != nil && parseError != io.EOF {
+			return nil, &MailEventError{"Delivery status has malformed recipient fields!"}
+		}
+
+		if event, known := toDsnEvent(recipient, occurredAt); known {
+			events = append(events, event)
+		}
+	}
+
+	return events, nil
+}
+
+func toDsnEvent(fields textproto.MIMEHeader, occurredAt time.Time) (event MailEvent, known bool) {
+	address := fields.Get("Final-Recipient")
+
+	if separator := strings.Index(address, ";"); separator >= 0 {
+		address = address[separator+1:]
+	}
+
+	event = MailEvent{Recipient: strings.Trim(strings.TrimSpace(address), "<>"), OccurredAt: occurredAt}
+	event.Reason = strings.TrimSpace(fields.Get("Status") + " " + fields.Get("Diagnostic-Code"))
+	status := fields.Get("Status")
+
+	if event.Recipient == "" {
+		return event, false
+	}
+
+	switch strings.ToLower(fields.Get("Action")) {
+	case "failed":
+		event.Kind = MailEventSoftBounce
+		if strings.HasPrefix(status, "5") {
+			event.Kind = MailEventHardBounce
+		}
+	case "delayed":
+		event.Kind = MailEventSoftBounce
+	case "delivered", "relayed":
+		event.Kind = MailEventDelivered
+	default:
+		return event, false
+	}
+
+	return event, true
+}
+
+func getMailgunEvent(context echo.Context) (*mailgunEvent, error) {
+	if parsed, found := context.Get(mailgunEventContextKey).(*mailgunEvent); found {
+		return parsed, nil
+	}
+
+	body, readError := ioutil.ReadAll(context.Request().Body())
+
+	if readError != nil {
+		return nil, readError
+	}
+
+	webhook := new(mailgunEvent)
+
+	if jsonError := json.Unmarshal(body, webhook); jsonError != nil {
+		return nil, jsonError
+	}
+
+	context.Set(mailgunEventContextKey, webhook)
+	return webhook, nil
+}
+
+func postMailgunEvent(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		if MailgunSigningKey == "" {
+			return context.String(http.StatusForbidden, "Mailgun events are disabled!")
+		}
+
+		webhook, parseError := getMailgunEvent(context)
+
+		if parseError != nil {
+			return context.String(http.StatusBadRequest, "Body is not a Mailgun event!")
+		}
+
+		if !verifyMailgunSignature(webhook.Signature.Timestamp, webhook.Signature.Token, webhook.Signature.Signature, repositories.Clock.Now()) {
+			return context.String(http.StatusUnauthorized, "Invalid Mailgun signature!")
+		}
+
+		event, known := webhook.toMailEvent(repositories.Clock.Now())
+
+		if !known {
+			return context.NoContent(http.StatusNoContent)
+		}
+
+		health, databaseError := applyMailEvent(database, repositories, event)
+
+		if databaseError == ErrNotFound {
+			return context.NoContent(http.StatusNoContent)
+		} else if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, health)
+		}
+	})
+}
+
+func postDsn(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		events, parseError := parseDsn(context.Request().Body(), repositories.Clock.Now())
+
+		if mailEventError, invalid := parseError.(*MailEventError); invalid {
+			return context.String(http.StatusBadRequest, mailEventError.Message)
+		}
+
+		applied := []MailEvent{}
+
+		for _, event := range events {
+			if _, databaseError := applyMailEvent(database, repositories, event); databaseError == nil {
+				applied = append(applied, event)
+			} else if databaseError != ErrNotFound {
+				return databaseError
+			}
+		}
+
+		return context.JSON(http.StatusOK, applied)
+	})
+}
+
+func getSubscriberDeliveryHealth(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		uuid := context.Param("uuid")
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
+
+		if databaseError == ErrNotFound {
+			return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		health, databaseError := getDeliveryHealth(database, uuid)
+
+		if databaseError != nil {
+			return databaseError
+		}
+
+		deliveries, databaseError := getMailDeliveries(repositories.MailTasks, uuid)
+
+		if databaseError != nil {
+			return databaseError
+		}
+
+		report := DeliveryHealthReport{DeliveryHealth: *health, Status: subscriber.Status}
+
+		for _, delivery := range deliveries {
+			if delivery.Status == DeliverySent {
+				report.MailsSent++
+			} else {
+				report.MailsFailed++
+			}
+		}
+
+		return context.JSON(http.StatusOK, report)
+	})
+}
@@ -0,0 +1,25 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMailgunSignatureRejectsReplays(t *testing.T) {
+	signingKey := MailgunSigningKey
+	MailgunSigningKey = "test-signing-key"
+	defer func() { MailgunSigningKey = signingKey }()
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(MailgunSigningKey))
+	mac.Write([]byte(timestamp + "replayed-token"))
+	signature := hex.EncodeToString(mac.Sum(nil))
+
+	expect(t, !verifyMailgunSignature(timestamp, "replayed-token", "x"+signature[1:], time.Now()), "a wrong signature must be rejected")
+	expect(t, verifyMailgunSignature(timestamp, "replayed-token", signature, time.Now()), "a fresh signed request must be accepted")
+	expect(t, !verifyMailgunSignature(timestamp, "replayed-token", signature, time.Now()), "a replayed request must be rejected")
+}
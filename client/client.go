@@ -0,0 +1,527 @@
+package client
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+type (
+	Client struct {
+		BaseUrl    string
+		AdminToken string
+		HTTPClient *http.Client
+	}
+
+	Error struct {
+		StatusCode int
+		Message    string
+	}
+
+	MoodStream struct {
+		LastEventId string
+		body        io.ReadCloser
+		reader      *bufio.Reader
+	}
+)
+
+const StreamReset = "reset"
+
+func New(baseUrl string) *Client {
+	return &Client{BaseUrl: strings.TrimRight(baseUrl, "/"), HTTPClient: http.DefaultClient}
+}
+
+func (client *Client) WithAdminToken(token string) *Client {
+	copied := *client
+	copied.AdminToken = token
+	return &copied
+}
+
+func (client *Client) ForOrganization(id string) *Client {
+	copied := *client
+	copied.BaseUrl = client.BaseUrl + "/orgs/" + url.PathEscape(id)
+	return &copied
+}
+
+func (responseError *Error) Error() string {
+	return fmt.Sprintf("mutservice answered with status %d: %s", responseError.StatusCode, responseError.Message)
+}
+
+func IsNotFound(err error) bool {
+	responseError, isResponseError := err.(*Error)
+	return isResponseError && responseError.StatusCode == http.StatusNotFound
+}
+
+func (client *Client) do(method string, path string, query url.Values, contentType string, body io.Reader, expected int) (*http.Response, error) {
+	address := client.BaseUrl + path
+	if len(query) > 0 {
+		address += "?" + query.Encode()
+	}
+
+	request, requestError := http.NewRequest(method, address, body)
+
+	if requestError != nil {
+		return nil, requestError
+	}
+
+	if contentType != "" {
+		request.Header.Set("Content-Type", contentType)
+	}
+	if client.AdminToken != "" {
+		request.Header.Set("Authorization", "Bearer "+client.AdminToken)
+	}
+
+	response, responseError := client.HTTPClient.Do(request)
+
+	if responseError != nil {
+		return nil, responseError
+	}
+
+	if response.StatusCode != expected {
+		defer response.Body.Close()
+		message, _ := ioutil.ReadAll(response.Body)
+		return nil, &Error{response.StatusCode, strings.TrimSpace(string(message))}
+	}
+
+	return response, nil
+}
+
+func (client *Client) call(method string, path string, query url.Values, request interface{}, expected int, result interface{}) error {
+	var body io.Reader
+	contentType := ""
+
+	if request != nil {
+		encoded, jsonError := json.Marshal(request)
+
+		if jsonError != nil {
+			return jsonError
+		}
+		body = bytes.NewReader(encoded)
+		contentType = "application/json"
+	}
+
+	response, responseError := client.do(method, path, query, contentType, body, expected)
+
+	if responseError != nil {
+		return responseError
+	}
+
+	defer response.Body.Close()
+
+	if result == nil {
+		return nil
+	}
+	return json.NewDecoder(response.Body).Decode(result)
+}
+
+func (options ListOptions) query() url.Values {
+	query := url.Values{}
+
+	if options.Limit > 0 {
+		query.Set("limit", strconv.Itoa(options.Limit))
+	}
+	if options.Cursor != "" {
+		query.Set("cursor", options.Cursor)
+	}
+	if options.Order != "" {
+		query.Set("order", options.Order)
+	}
+
+	return query
+}
+
+func setIfPresent(query url.Values, name string, value string) {
+	if value != "" {
+		query.Set(name, value)
+	}
+}
+
+func (client *Client) list(path string, query url.Values, result interface{}) (next string, total int, err error) {
+	response, responseError := client.do(http.MethodGet, path, query, "", nil, http.StatusOK)
+
+	if responseError != nil {
+		return "", 0, responseError
+	}
+
+	defer response.Body.Close()
+
+	total, _ = strconv.Atoi(response.Header.Get("X-Total-Count"))
+	next = getNextCursor(response.Header.Get("Link"))
+
+	return next, total, json.NewDecoder(response.Body).Decode(result)
+}
+
+func getNextCursor(header string) string {
+	for _, link := range strings.Split(header, ",") {
+		parts := strings.Split(link, ";")
+
+		if len(parts) < 2 || strings.TrimSpace(parts[1]) != `rel="next"` {
+			continue
+		}
+
+		address, parseError := url.Parse(strings.Trim(strings.TrimSpace(parts[0]), "<>"))
+
+		if parseError == nil {
+			return address.Query().Get("cursor")
+		}
+	}
+	return ""
+}
+
+func (client *Client) Subscribers(filter SubscriberFilter, options ListOptions) (page *SubscriberPage, err error) {
+	query := options.query()
+	setIfPresent(query, "email-prefix", filter.EmailPrefix)
+	setIfPresent(query, "status", filter.Status)
+
+	page = new(SubscriberPage)
+	page.Next, page.Total, err = client.list("/subscribers", query, &page.Subscribers)
+	return page, err
+}
+
+func (client *Client) Subscriber(uuid string) (subscriber *Subscriber, err error) {
+	subscriber = new(Subscriber)
+	err = client.call(http.MethodGet, "/subscribers/"+url.PathEscape(uuid), nil, nil, http.StatusOK, subscriber)
+	return subscriber, err
+}
+
+func (client *Client) Subscribe(subscription Subscription) (subscriber *Subscriber, err error) {
+	subscriber = new(Subscriber)
+	err = client.call(http.MethodPost, "/subscribers", nil, subscription, http.StatusCreated, subscriber)
+	return subscriber, err
+}
+
+func (client *Client) UpdateSubscriber(uuid string, patch map[string]interface{}) (subscriber *Subscriber, err error) {
+	encoded, jsonError := json.Marshal(patch)
+
+	if jsonError != nil {
+		return nil, jsonError
+	}
+
+	response, responseError := client.do(http.MethodPatch, "/subscribers/"+url.PathEscape(uuid), nil, "application/merge-patch+json", bytes.NewReader(encoded), http.StatusOK)
+
+	if responseError != nil {
+		return nil, responseError
+	}
+
+	defer response.Body.Close()
+
+	subscriber = new(Subscriber)
+	return subscriber, json.NewDecoder(response.Body).Decode(subscriber)
+}
+
+func (client *Client) PauseSubscriber(uuid string, pause Pause) (subscriber *Subscriber, err error) {
+	subscriber = new(Subscriber)
+	err = client.call(http.MethodPost, "/subscribers/"+url.PathEscape(uuid)+"/pause", nil, pause, http.StatusOK, subscriber)
+	return subscriber, err
+}
+
+func (client *Client) ResumeSubscriber(uuid string) (subscriber *Subscriber, err error) {
+	subscriber = new(Subscriber)
+	err = client.call(http.MethodDelete, "/subscribers/"+url.PathEscape(uuid)+"/pause", nil, nil, http.StatusOK, subscriber)
+	return subscriber, err
+}
+
+func (client *Client) SubscriberData(uuid string) (data *SubscriberData, err error) {
+	data = new(SubscriberData)
+	err = client.call(http.MethodGet, "/subscribers/"+url.PathEscape(uuid)+"/data", nil, nil, http.StatusOK, data)
+	return data, err
+}
+
+func (client *Client) DeliveryHealth(uuid string) (report *DeliveryHealthReport, err error) {
+	report = new(DeliveryHealthReport)
+	err = client.call(http.MethodGet, "/subscribers/"+url.PathEscape(uuid)+"/delivery-health", nil, nil, http.StatusOK, report)
+	return report, err
+}
+
+func (client *Client) ReportDeliveryStatus(message io.Reader) (events []MailEvent, err error) {
+	response, responseError := client.do(http.MethodPost, "/mail/events/dsn", nil, "message/rfc822", message, http.StatusOK)
+
+	if responseError != nil {
+		return nil, responseError
+	}
+
+	defer response.Body.Close()
+
+	return events, json.NewDecoder(response.Body).Decode(&events)
+}
+
+func (client *Client) DeleteSubscriber(uuid string) error {
+	return client.call(http.MethodDelete, "/subscribers/"+url.PathEscape(uuid), nil, nil, http.StatusNoContent, nil)
+}
+
+func (client *Client) AuditTrail() (entries []AuditEntry, err error) {
+	err = client.call(http.MethodGet, "/admin/audit", nil, nil, http.StatusOK, &entries)
+	return entries, err
+}
+
+func (client *Client) Purge(dryRun bool) (report *PurgeReport, err error) {
+	report = new(PurgeReport)
+	err = client.call(http.MethodPost, "/admin/purge", url.Values{"dry-run": {strconv.FormatBool(dryRun)}}, nil, http.StatusOK, report)
+	return report, err
+}
+
+func (client *Client) Backup(writer io.Writer) error {
+	response, responseError := client.do(http.MethodGet, "/admin/backup", nil, "", nil, http.StatusOK)
+
+	if responseError != nil {
+		return responseError
+	}
+
+	defer response.Body.Close()
+
+	_, copyError := io.Copy(writer, response.Body)
+	return copyError
+}
+
+func (client *Client) Moods(filter MoodFilter, options ListOptions) (page *MoodPage, err error) {
+	query := options.query()
+	setIfPresent(query, "from", filter.From)
+	setIfPresent(query, "to", filter.To)
+
+	page = new(MoodPage)
+	page.Next, page.Total, err = client.list("/moods", query, &page.Moods)
+	return page, err
+}
+
+func (client *Client) MoodStream(lastEventId string) (*MoodStream, error) {
+	query := url.Values{}
+	setIfPresent(query, "last-event-id", lastEventId)
+
+	response, responseError := client.do(http.MethodGet, "/moods/stream", query, "", nil, http.StatusOK)
+
+	if responseError != nil {
+		return nil, responseError
+	}
+
+	return &MoodStream{LastEventId: lastEventId, body: response.Body, reader: bufio.NewReader(response.Body)}, nil
+}
+
+func (stream *MoodStream) Next() (event MoodEvent, err error) {
+	var data []string
+
+	for {
+		line, readError := stream.reader.ReadString('\n')
+
+		if readError != nil {
+			return event, readError
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if line == "" {
+			if len(data) == 0 {
+				event = MoodEvent{}
+				continue
+			}
+
+			if event.Type != StreamReset {
+				err = json.Unmarshal([]byte(strings.Join(data, "\n")), &event.Moods)
+			}
+			if event.Id != "" {
+				stream.LastEventId = event.Id
+			}
+			return event, err
+		}
+
+		field, value := line, ""
+		if colon := strings.Index(line, ":"); colon >= 0 {
+			field, value = line[:colon], strings.TrimPrefix(line[colon+1:], " ")
+		}
+
+		switch field {
+		case "id":
+			event.Id = value
+		case "event":
+			event.Type = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+}
+
+func (stream *MoodStream) Close() error {
+	return stream.body.Close()
+}
+
+func (client *Client) Vote(key string, mood Mood) error {
+	return client.Answer(key, Answers{"mood": {strconv.Itoa(mood.Value)}})
+}
+
+func (client *Client) Answer(key string, answers Answers) error {
+	body := strings.NewReader(url.Values(answers).Encode())
+	response, responseError := client.do(http.MethodPost, "/moods/"+url.PathEscape(key), nil, "application/x-www-form-urlencoded", body, http.StatusCreated)
+
+	if responseError != nil {
+		return responseError
+	}
+
+	return response.Body.Close()
+}
+
+func (client *Client) Reply(message io.Reader) error {
+	response, responseError := client.do(http.MethodPost, "/mail/replies/raw", nil, "message/rfc822", message, http.StatusCreated)
+
+	if responseError != nil {
+		return responseError
+	}
+
+	return response.Body.Close()
+}
+
+func (client *Client) Surveys() (surveys []Survey, err error) {
+	err = client.call(http.MethodGet, "/surveys", nil, nil, http.StatusOK, &surveys)
+	return surveys, err
+}
+
+func (client *Client) Survey(id string, version int) (survey *Survey, err error) {
+	query := url.Values{}
+	if version > 0 {
+		query.Set("version", strconv.Itoa(version))
+	}
+
+	survey = new(Survey)
+	err = client.call(http.MethodGet, "/surveys/"+url.PathEscape(id), query, nil, http.StatusOK, survey)
+	return survey, err
+}
+
+func (client *Client) SurveyResults(id string) (results []SurveyResults, err error) {
+	err = client.call(http.MethodGet, "/surveys/"+url.PathEscape(id)+"/results", nil, nil, http.StatusOK, &results)
+	return results, err
+}
+
+func (client *Client) CreateSurvey(survey Survey) (created *Survey, err error) {
+	created = new(Survey)
+	err = client.call(http.MethodPost, "/surveys", nil, survey, http.StatusCreated, created)
+	return created, err
+}
+
+func (client *Client) UpdateSurvey(survey Survey) (updated *Survey, err error) {
+	updated = new(Survey)
+	err = client.call(http.MethodPut, "/surveys/"+url.PathEscape(survey.Id), nil, survey, http.StatusCreated, updated)
+	return updated, err
+}
+
+func (client *Client) ActivateSurvey(id string) error {
+	return client.call(http.MethodPost, "/surveys/"+url.PathEscape(id)+"/activate", nil, nil, http.StatusNoContent, nil)
+}
+
+func (client *Client) Responses(dateString string) (responses []Response, err error) {
+	err = client.call(http.MethodGet, "/admin/responses", url.Values{"date": {dateString}}, nil, http.StatusOK, &responses)
+	return responses, err
+}
+
+func (client *Client) DeleteResponse(id string) error {
+	return client.call(http.MethodDelete, "/admin/responses/"+url.PathEscape(id), nil, nil, http.StatusNoContent, nil)
+}
+
+func (client *Client) RebuildRollups(dateString string) (report *RebuildReport, err error) {
+	query := url.Values{}
+	if dateString != "" {
+		query.Set("date", dateString)
+	}
+
+	report = new(RebuildReport)
+	err = client.call(http.MethodPost, "/admin/rollups/rebuild", query, nil, http.StatusOK, report)
+	return report, err
+}
+
+func (client *Client) Alerts() (alerts []Alert, err error) {
+	err = client.call(http.MethodGet, "/alerts", nil, nil, http.StatusOK, &alerts)
+	return alerts, err
+}
+
+func (client *Client) AlertRules() (rules []AlertRule, err error) {
+	err = client.call(http.MethodGet, "/alerts/rules", nil, nil, http.StatusOK, &rules)
+	return rules, err
+}
+
+func (client *Client) CreateAlertRule(rule AlertRule) (created *AlertRule, err error) {
+	created = new(AlertRule)
+	err = client.call(http.MethodPost, "/alerts/rules", nil, rule, http.StatusCreated, created)
+	return created, err
+}
+
+func (client *Client) DeleteAlertRule(id string) error {
+	return client.call(http.MethodDelete, "/alerts/rules/"+url.PathEscape(id), nil, nil, http.StatusNoContent, nil)
+}
+
+func (client *Client) Webhooks() (webhooks []Webhook, err error) {
+	err = client.call(http.MethodGet, "/webhooks", nil, nil, http.StatusOK, &webhooks)
+	return webhooks, err
+}
+
+func (client *Client) CreateWebhook(webhook Webhook) (created *Webhook, err error) {
+	created = new(Webhook)
+	err = client.call(http.MethodPost, "/webhooks", nil, webhook, http.StatusCreated, created)
+	return created, err
+}
+
+func (client *Client) DeleteWebhook(id string) error {
+	return client.call(http.MethodDelete, "/webhooks/"+url.PathEscape(id), nil, nil, http.StatusNoContent, nil)
+}
+
+func (client *Client) WebhookDeliveries(id string) (deliveries []WebhookDelivery, err error) {
+	err = client.call(http.MethodGet, "/webhooks/"+url.PathEscape(id)+"/deliveries", nil, nil, http.StatusOK, &deliveries)
+	return deliveries, err
+}
+
+func (client *Client) Redeliver(id string, delivery string) (queued *WebhookDelivery, err error) {
+	queued = new(WebhookDelivery)
+	err = client.call(http.MethodPost, "/webhooks/"+url.PathEscape(id)+"/deliveries/"+url.PathEscape(delivery)+"/redeliver", nil, nil, http.StatusAccepted, queued)
+	return queued, err
+}
+
+func (client *Client) Tokens() (tokens []Token, err error) {
+	err = client.call(http.MethodGet, "/admin/tokens", nil, nil, http.StatusOK, &tokens)
+	return tokens, err
+}
+
+func (client *Client) CreateToken(label string) (created *Token, err error) {
+	created = new(Token)
+	err = client.call(http.MethodPost, "/admin/tokens", nil, Token{Label: label}, http.StatusCreated, created)
+	return created, err
+}
+
+func (client *Client) RevokeToken(id string) error {
+	return client.call(http.MethodDelete, "/admin/tokens/"+url.PathEscape(id), nil, nil, http.StatusNoContent, nil)
+}
+
+func (client *Client) Organizations() (organizations []Organization, err error) {
+	err = client.call(http.MethodGet, "/organizations", nil, nil, http.StatusOK, &organizations)
+	return organizations, err
+}
+
+func (client *Client) Organization(id string) (organization *Organization, err error) {
+	organization = new(Organization)
+	err = client.call(http.MethodGet, "/organizations/"+url.PathEscape(id), nil, nil, http.StatusOK, organization)
+	return organization, err
+}
+
+func (client *Client) CreateOrganization(organization Organization) (created *Organization, err error) {
+	created = new(Organization)
+	err = client.call(http.MethodPost, "/organizations", nil, organization, http.StatusCreated, created)
+	return created, err
+}
+
+func (client *Client) UpdateOrganization(organization Organization) (updated *Organization, err error) {
+	updated = new(Organization)
+	err = client.call(http.MethodPut, "/organizations/"+url.PathEscape(organization.Id), nil, organization, http.StatusOK, updated)
+	return updated, err
+}
+
+func VerifyWebhook(secret string, timestamp string, payload []byte, signature string) bool {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hmac.Equal([]byte(signature), []byte("sha256="+hex.EncodeToString(mac.Sum(nil))))
+}
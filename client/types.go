@@ -0,0 +1,299 @@
+package client
+
+import (
+	"time"
+)
+
+type (
+	Subscription struct {
+		Email       string            `json:"email"`
+		Team        string            `json:"team"`
+		Name        string            `json:"name,omitempty"`
+		Locale      string            `json:"locale,omitempty"`
+		TimeZone    string            `json:"time-zone,omitempty"`
+		Channel     string            `json:"channel,omitempty"`
+		Attributes  map[string]string `json:"attributes,omitempty"`
+		NoReminders bool              `json:"no-reminders,omitempty"`
+		Frequency   string            `json:"frequency,omitempty"`
+		Weekdays    []string          `json:"weekdays,omitempty"`
+	}
+
+	Subscriber struct {
+		Uuid          string            `json:"uuid"`
+		Email         string            `json:"email"`
+		Team          string            `json:"team,omitempty"`
+		Status        string            `json:"status"`
+		Name          string            `json:"name,omitempty"`
+		Locale        string            `json:"locale,omitempty"`
+		TimeZone      string            `json:"time-zone,omitempty"`
+		Channel       string            `json:"channel,omitempty"`
+		Attributes    map[string]string `json:"attributes,omitempty"`
+		NoReminders   bool              `json:"no-reminders,omitempty"`
+		PausedFrom    string            `json:"paused-from,omitempty"`
+		PausedUntil   string            `json:"paused-until,omitempty"`
+		Frequency     string            `json:"frequency,omitempty"`
+		Weekdays      []string          `json:"weekdays,omitempty"`
+		FrequencyFrom string            `json:"frequency-from,omitempty"`
+		CreatedAt     time.Time         `json:"created-at"`
+		UpdatedAt     time.Time         `json:"updated-at"`
+	}
+
+	Pause struct {
+		From  string `json:"from,omitempty"`
+		Until string `json:"until,omitempty"`
+	}
+
+	ListOptions struct {
+		Limit  int
+		Cursor string
+		Order  string
+	}
+
+	SubscriberFilter struct {
+		EmailPrefix string
+		Status      string
+	}
+
+	MoodFilter struct {
+		From string
+		To   string
+	}
+
+	SubscriberPage struct {
+		Subscribers []Subscriber
+		Next        string
+		Total       int
+	}
+
+	MoodPage struct {
+		Moods []PublishedMoods
+		Next  string
+		Total int
+	}
+
+	ScalePoint struct {
+		Value int    `json:"value"`
+		Label string `json:"label"`
+		Emoji string `json:"emoji"`
+	}
+
+	Scale struct {
+		Name   string       `json:"name"`
+		Points []ScalePoint `json:"points"`
+	}
+
+	DailyMoods struct {
+		DateString    string          `json:"date"`
+		SurveyId      string          `json:"survey,omitempty"`
+		SurveyVersion int             `json:"survey-version,omitempty"`
+		Scale         Scale           `json:"scale"`
+		Counts        map[int]int     `json:"counts"`
+		Weights       map[int]float64 `json:"weights,omitempty"`
+		Invited       int             `json:"invited"`
+		Paused        int             `json:"paused,omitempty"`
+		FromResponses bool            `json:"from-responses,omitempty"`
+	}
+
+	PublishedMoods struct {
+		DailyMoods
+		Period       string  `json:"period"`
+		Until        string  `json:"until"`
+		WeightedMean float64 `json:"weighted-mean,omitempty"`
+	}
+
+	MoodEvent struct {
+		Id    string
+		Type  string
+		Moods PublishedMoods
+	}
+
+	Mood struct {
+		Value int `json:"mood"`
+	}
+
+	Question struct {
+		Id       string   `json:"id"`
+		Text     string   `json:"text"`
+		Type     string   `json:"type"`
+		Scale    string   `json:"scale,omitempty"`
+		Choices  []string `json:"choices,omitempty"`
+		Required bool     `json:"required"`
+		Order    int      `json:"order"`
+	}
+
+	Survey struct {
+		Id        string     `json:"id"`
+		Version   int        `json:"version"`
+		Name      string     `json:"name"`
+		Subject   string     `json:"subject"`
+		Questions []Question `json:"questions"`
+		CreatedAt time.Time  `json:"created-at"`
+	}
+
+	QuestionAggregate struct {
+		Answered   int            `json:"answered"`
+		Counts     map[string]int `json:"counts,omitempty"`
+		Texts      []string       `json:"texts,omitempty"`
+		Suppressed bool           `json:"suppressed,omitempty"`
+	}
+
+	SurveyResults struct {
+		DateString    string                        `json:"date"`
+		SurveyId      string                        `json:"survey"`
+		SurveyVersion int                           `json:"version"`
+		Answers       map[string]*QuestionAggregate `json:"answers"`
+	}
+
+	Answers map[string][]string
+
+	Response struct {
+		Id            string    `json:"id"`
+		DateString    string    `json:"date"`
+		SurveyId      string    `json:"survey"`
+		SurveyVersion int       `json:"survey-version"`
+		Answers       Answers   `json:"answers"`
+		SubmittedAt   time.Time `json:"submitted-at"`
+		Channel       string    `json:"channel"`
+		Team          string    `json:"team,omitempty"`
+		Weight        float64   `json:"weight,omitempty"`
+	}
+
+	RebuildReport struct {
+		Rebuilt []string `json:"rebuilt"`
+		Skipped []string `json:"skipped"`
+	}
+
+	FeedbackIdentifier struct {
+		Key        string
+		DateString string
+		Team       string
+	}
+
+	MailDelivery struct {
+		Id             string    `json:"id"`
+		SubscriberUuid string    `json:"subscriber"`
+		Email          string    `json:"email"`
+		Key            string    `json:"key"`
+		Subject        string    `json:"subject"`
+		Kind           string    `json:"kind,omitempty"`
+		Status         string    `json:"status"`
+		Error          string    `json:"error,omitempty"`
+		SentAt         time.Time `json:"sent-at"`
+	}
+
+	SubscriberData struct {
+		Subscriber   Subscriber           `json:"subscriber"`
+		FeedbackKeys []FeedbackIdentifier `json:"feedback-keys"`
+		Deliveries   []MailDelivery       `json:"deliveries"`
+		Health       *DeliveryHealth      `json:"delivery-health"`
+		ExportedAt   time.Time            `json:"exported-at"`
+		ExportedOn   string               `json:"exported-on"`
+	}
+
+	DeliveryHealth struct {
+		SubscriberUuid string    `json:"subscriber"`
+		Delivered      int       `json:"delivered"`
+		HardBounces    int       `json:"hard-bounces"`
+		SoftBounces    int       `json:"soft-bounces"`
+		Complaints     int       `json:"complaints"`
+		Unsubscribes   int       `json:"unsubscribes"`
+		Flagged        bool      `json:"flagged"`
+		LastEvent      string    `json:"last-event,omitempty"`
+		LastReason     string    `json:"last-reason,omitempty"`
+		LastEventAt    time.Time `json:"last-event-at,omitempty"`
+		SuppressedAt   time.Time `json:"suppressed-at,omitempty"`
+	}
+
+	DeliveryHealthReport struct {
+		DeliveryHealth
+		Status      string `json:"status"`
+		MailsSent   int    `json:"mails-sent"`
+		MailsFailed int    `json:"mails-failed"`
+	}
+
+	MailEvent struct {
+		Recipient  string    `json:"recipient"`
+		Kind       string    `json:"kind"`
+		Reason     string    `json:"reason,omitempty"`
+		OccurredAt time.Time `json:"occurred-at"`
+	}
+
+	AuditEntry struct {
+		Id        string    `json:"id"`
+		Action    string    `json:"action"`
+		Subject   string    `json:"subject"`
+		Details   string    `json:"details"`
+		CreatedAt time.Time `json:"created-at"`
+	}
+
+	PurgeReport struct {
+		DryRun            bool      `json:"dry-run"`
+		FeedbackKeys      int       `json:"feedback-keys"`
+		MailDeliveries    int       `json:"mail-deliveries"`
+		WebhookDeliveries int       `json:"webhook-deliveries"`
+		Comments          int       `json:"comments"`
+		Responses         int       `json:"responses"`
+		StartedAt         time.Time `json:"started-at"`
+		FinishedAt        time.Time `json:"finished-at"`
+	}
+
+	Webhook struct {
+		Id        string    `json:"id,omitempty"`
+		Url       string    `json:"url"`
+		Events    []string  `json:"events"`
+		Secret    string    `json:"secret,omitempty"`
+		CreatedAt time.Time `json:"created-at"`
+	}
+
+	WebhookDelivery struct {
+		Id            string    `json:"id"`
+		WebhookId     string    `json:"webhook"`
+		EventId       string    `json:"event"`
+		EventType     string    `json:"event-type"`
+		Payload       string    `json:"payload"`
+		Status        string    `json:"status"`
+		Attempts      int       `json:"attempts"`
+		StatusCode    int       `json:"status-code,omitempty"`
+		Error         string    `json:"error,omitempty"`
+		CreatedAt     time.Time `json:"created-at"`
+		NextAttemptAt time.Time `json:"next-attempt-at,omitempty"`
+		DeliveredAt   time.Time `json:"delivered-at,omitempty"`
+	}
+
+	AlertRule struct {
+		Id        string  `json:"id"`
+		Name      string  `json:"name"`
+		Kind      string  `json:"kind"`
+		Window    int     `json:"window"`
+		Baseline  int     `json:"baseline"`
+		Threshold float64 `json:"threshold"`
+		Channel   string  `json:"channel"`
+		Target    string  `json:"target"`
+		Secret    string  `json:"secret,omitempty"`
+	}
+
+	Alert struct {
+		RuleId     string    `json:"rule"`
+		State      string    `json:"state"`
+		Value      float64   `json:"value"`
+		DateString string    `json:"date"`
+		FiredAt    time.Time `json:"fired-at"`
+		ResolvedAt time.Time `json:"resolved-at"`
+	}
+
+	Organization struct {
+		Id             string    `json:"id"`
+		Name           string    `json:"name"`
+		MailFrom       string    `json:"mail-from,omitempty"`
+		ReplyAddress   string    `json:"reply-address,omitempty"`
+		SurveySchedule string    `json:"survey-schedule,omitempty"`
+		CreatedAt      time.Time `json:"created-at"`
+	}
+
+	Token struct {
+		Id        string    `json:"id,omitempty"`
+		Label     string    `json:"label,omitempty"`
+		Token     string    `json:"token,omitempty"`
+		CreatedAt time.Time `json:"created-at"`
+	}
+)
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"time"
+)
+
+type (
+	Clock interface {
+		Now() time.Time
+	}
+
+	systemClock struct{}
+)
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
@@ -5,8 +5,67 @@ import (
 	"github.com/robfig/cron"
 )
 
-func createCronJob(database *storm.DB, command func()) {
-	scheduler := cron.New()
-	scheduler.AddFunc("0 15 13 * * *", command)
-	scheduler.Start()
+type (
+	Scheduler interface {
+		Schedule(spec string, commands ...func()) error
+		Start()
+		Stop()
+	}
+
+	cronScheduler struct {
+		cron *cron.Cron
+	}
+)
+
+var SurveySchedule string = getEnvString("MUT_SURVEY_SCHEDULE", "0 15 13 * * *")
+var PurgeSchedule string = getEnvString("MUT_PURGE_SCHEDULE", "0 30 3 * * *")
+
+func scheduleJobs(scheduler Scheduler, database *storm.DB, repositories Repositories) error {
+	if scheduleError := scheduler.Schedule(getSurveySchedule(repositories.Organization), closeDay(database, repositories), triggerMail(database, repositories)); scheduleError != nil {
+		return scheduleError
+	}
+
+	if scheduleError := scheduler.Schedule(PurgeSchedule, purgeStaleRecords(database, repositories)); scheduleError != nil {
+		return scheduleError
+	}
+
+	if scheduleError := scheduler.Schedule(WebhookSchedule, deliverWebhooks(database, repositories)); scheduleError != nil {
+		return scheduleError
+	}
+
+	if ReminderDelayHours > 0 {
+		if scheduleError := scheduler.Schedule(ReminderSchedule, sendReminders(repositories)); scheduleError != nil {
+			return scheduleError
+		}
+	}
+
+	if BackupDirectory != "" {
+		return scheduler.Schedule(BackupSchedule, writeSnapshot(database, repositories, getBackupDirectory(repositories.Organization)))
+	}
+
+	return nil
+}
+
+func runAll(commands []func()) func() {
+	return func() {
+		for _, command := range commands {
+			command()
+		}
+	}
+}
+
+func newCronScheduler() *cronScheduler {
+	return &cronScheduler{cron.New()}
+}
+
+func (scheduler *cronScheduler) Schedule(spec string, commands ...func()) error {
+	return scheduler.cron.AddFunc(spec, runAll(commands))
+}
+
+func (scheduler *cronScheduler) Start() {
+	scheduler.cron.Start()
+}
+
+func (scheduler *cronScheduler) Stop() {
+	scheduler.cron.Stop()
 }
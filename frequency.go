@@ -0,0 +1,106 @@
+package main
+
+import (
+	"strings"
+	"time"
+)
+
+const (
+	FrequencyDaily    = "daily"
+	FrequencyWeekdays = "weekdays"
+	FrequencyWeekly   = "weekly"
+	FrequencyBiweekly = "biweekly"
+)
+
+var weekdayNames = map[string]time.Weekday{}
+
+func init() {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		weekdayNames[strings.ToLower(day.String())] = day
+	}
+}
+
+func validateFrequency(frequency string, weekdays []string, from string) error {
+	if from != "" {
+		if _, parseError := time.Parse(dateLayout, from); parseError != nil {
+			return &ProfileError{"Frequency start '" + from + "' must be a date like " + dateLayout + "!"}
+		}
+	}
+
+	for _, weekday := range weekdays {
+		if _, known := weekdayNames[weekday]; !known {
+			return &ProfileError{"Weekday '" + weekday + "' is unknown, use lower case English names!"}
+		}
+	}
+
+	switch frequency {
+	case "", FrequencyDaily:
+		if len(weekdays) > 0 {
+			return &ProfileError{"Daily surveys cannot be limited to weekdays!"}
+		}
+	case FrequencyWeekdays:
+		if len(weekdays) == 0 {
+			return &ProfileError{"Frequency '" + FrequencyWeekdays + "' needs at least one weekday!"}
+		}
+	case FrequencyWeekly, FrequencyBiweekly:
+		if len(weekdays) != 1 {
+			return &ProfileError{"Frequency '" + frequency + "' needs exactly one weekday!"}
+		}
+	default:
+		return &ProfileError{"Frequency must be '" + FrequencyDaily + "', '" + FrequencyWeekdays + "', '" + FrequencyWeekly + "' or '" + FrequencyBiweekly + "'!"}
+	}
+
+	return nil
+}
+
+func (subscriber *Subscriber) hasWeekday(day time.Weekday) bool {
+	for _, weekday := range subscriber.Weekdays {
+		if weekdayNames[weekday] == day {
+			return true
+		}
+	}
+	return false
+}
+
+func (subscriber *Subscriber) isDueOn(now time.Time) bool {
+	location := getLocation(subscriber.TimeZone)
+	today := now.In(location)
+
+	switch subscriber.Frequency {
+	case FrequencyWeekdays, FrequencyWeekly:
+		return subscriber.hasWeekday(today.Weekday())
+	case FrequencyBiweekly:
+		first, parseError := time.Parse(dateLayout, subscriber.FrequencyFrom)
+
+		if parseError != nil {
+			return false
+		}
+
+		current := time.Date(today.Year(), today.Month(), today.Day(), 0, 0, 0, 0, time.UTC)
+		weeks := int(startOfWeek(current).Sub(startOfWeek(first)).Hours()/24) / 7
+		return subscriber.hasWeekday(today.Weekday()) && weeks%2 == 0
+	default:
+		return true
+	}
+}
+
+func (subscriber *Subscriber) anchorFrequency(now time.Time) {
+	if subscriber.Frequency != FrequencyBiweekly {
+		subscriber.FrequencyFrom = ""
+	} else if subscriber.FrequencyFrom == "" {
+		subscriber.FrequencyFrom = now.In(getLocation(subscriber.TimeZone)).Format(dateLayout)
+	}
+}
+
+func (subscriber *Subscriber) surveyWeight() float64 {
+	switch subscriber.Frequency {
+	case FrequencyWeekdays:
+		return 7 / float64(len(subscriber.Weekdays))
+	case FrequencyWeekly:
+		return 7
+	case FrequencyBiweekly:
+		return 14
+	default:
+		return 1
+	}
+}
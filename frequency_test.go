@@ -0,0 +1,39 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestBiweeklyAnchor(t *testing.T) {
+	subscriber := Subscriber{Frequency: FrequencyBiweekly, Weekdays: []string{"monday"}, TimeZone: "UTC"}
+	first := time.Date(2026, time.June, 1, 12, 0, 0, 0, time.UTC)
+	subscriber.anchorFrequency(first)
+
+	expect(t, subscriber.FrequencyFrom == "01-06-2026", "the anchor must be the day the frequency was chosen, got %s", subscriber.FrequencyFrom)
+
+	for week := 0; week < 6; week++ {
+		due := subscriber.isDueOn(first.AddDate(0, 0, 7*week))
+		expect(t, due == (week%2 == 0), "week %d: due is %t", week, due)
+	}
+}
+
+func TestWeightsNormalizedOverWindow(t *testing.T) {
+	dailyMoods := DailyMoods{DateString: "01-06-2026", Scale: LegacyScale}
+
+	for voter := 0; voter < 3; voter++ {
+		if addError := dailyMoods.AddMood("0", 1); addError != nil {
+			t.Fatal(addError)
+		}
+	}
+
+	if addError := dailyMoods.AddMood("4", 14); addError != nil {
+		t.Fatal(addError)
+	}
+
+	day := dailyMoods.WeightedSum(1) / dailyMoods.TotalWeight(1)
+	expect(t, day == 1, "a biweekly voter must count like any other voter within a day, mean %f", day)
+
+	month := dailyMoods.WeightedSum(30) / dailyMoods.TotalWeight(30)
+	expect(t, month > 3, "a biweekly voter must keep their weight over a month, mean %f", month)
+}
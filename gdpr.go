@@ -0,0 +1,186 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"github.com/nu7hatch/gouuid"
+	"net/http"
+	"time"
+)
+
+const (
+	AuditSubscriberExport = "subscriber.export"
+	AuditSubscriberErase  = "subscriber.erase"
+)
+
+type (
+	SubscriberData struct {
+		Subscriber   Subscriber           `json:"subscriber"`
+		FeedbackKeys []FeedbackIdentifier `json:"feedback-keys"`
+		Deliveries   []MailDelivery       `json:"deliveries"`
+		Health       *DeliveryHealth      `json:"delivery-health"`
+		ExportedAt   time.Time            `json:"exported-at"`
+		ExportedOn   string               `json:"exported-on"`
+	}
+
+	AuditEntry struct {
+		Id        string    `json:"id" storm:"id"`
+		Action    string    `json:"action" storm:"index"`
+		Subject   string    `json:"subject"`
+		Details   string    `json:"details"`
+		CreatedAt time.Time `json:"created-at"`
+	}
+)
+
+func pseudonymize(uuid string) string {
+	hash := sha256.Sum256([]byte(uuid))
+	return hex.EncodeToString(hash[:])
+}
+
+func saveAuditEntry(node storm.Node, action string, subscriberUuid string, details string, now time.Time) error {
+	id, _ := uuid.NewV4()
+	return node.Save(&AuditEntry{id.String(), action, pseudonymize(subscriberUuid), details, now})
+}
+
+func getPendingFeedbackIdentifiers(repositories Repositories, subscriberUuid string) (feedbackIdentifiers []FeedbackIdentifier, databaseError error) {
+	if feedbackIdentifiers, databaseError = repositories.FeedbackKeys.BySubscriber(subscriberUuid); feedbackIdentifiers == nil {
+		feedbackIdentifiers = []FeedbackIdentifier{}
+	}
+	return feedbackIdentifiers, databaseError
+}
+
+func getMailDeliveries(mailTasks MailTaskRepository, subscriberUuid string) (deliveries []MailDelivery, databaseError error) {
+	if deliveries, databaseError = mailTasks.BySubscriber(subscriberUuid); deliveries == nil {
+		deliveries = []MailDelivery{}
+	}
+	return deliveries, databaseError
+}
+
+func exportSubscriberData(database *storm.DB, repositories Repositories, subscriber *Subscriber) (data SubscriberData, databaseError error) {
+	data.Subscriber = *subscriber
+	data.ExportedAt = repositories.Clock.Now()
+	data.ExportedOn = formatLongDate(subscriber.Locale, data.ExportedAt.In(getLocation(subscriber.TimeZone)))
+
+	if data.FeedbackKeys, databaseError = getPendingFeedbackIdentifiers(repositories, subscriber.Uuid); databaseError != nil {
+		return data, databaseError
+	}
+
+	if data.Deliveries, databaseError = getMailDeliveries(repositories.MailTasks, subscriber.Uuid); databaseError != nil {
+		return data, databaseError
+	}
+
+	if data.Health, databaseError = getDeliveryHealth(database, subscriber.Uuid); databaseError != nil {
+		return data, databaseError
+	}
+
+	return data, saveAuditEntry(database, AuditSubscriberExport, subscriber.Uuid, "", repositories.Clock.Now())
+}
+
+func eraseSubscriber(database *storm.DB, repositories Repositories, subscriber *Subscriber) (databaseError error) {
+	transaction, databaseError := database.Begin(true)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	defer transaction.Rollback()
+
+	transactional := newStormRepositories(transaction)
+	transactional.Organization, transactional.Clock, transactional.Mailer = repositories.Organization, repositories.Clock, repositories.Mailer
+	feedbackIdentifiers, databaseError := getPendingFeedbackIdentifiers(transactional, subscriber.Uuid)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	for index := range feedbackIdentifiers {
+		if databaseError = transactional.FeedbackKeys.Remove(&feedbackIdentifiers[index]); databaseError != nil {
+			return databaseError
+		}
+	}
+
+	deliveries, databaseError := getMailDeliveries(transactional.MailTasks, subscriber.Uuid)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	for index := range deliveries {
+		if databaseError = transactional.MailTasks.Remove(&deliveries[index]); databaseError != nil {
+			return databaseError
+		}
+	}
+
+	if databaseError = ignoreNotFound(transaction.Remove(&DeliveryHealth{SubscriberUuid: subscriber.Uuid})); databaseError != nil {
+		return databaseError
+	}
+
+	if databaseError = transactional.Subscribers.Remove(subscriber); databaseError != nil {
+		return databaseError
+	}
+
+	details := fmt.Sprintf("removed subscriber, %d feedback keys and %d mail deliveries", len(feedbackIdentifiers), len(deliveries))
+
+	if databaseError = saveAuditEntry(transaction, AuditSubscriberErase, subscriber.Uuid, details, transactional.Clock.Now()); databaseError != nil {
+		return databaseError
+	}
+
+	return transaction.Commit()
+}
+
+func getSubscriberData(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		uuid := context.Param("uuid")
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
+
+		if databaseError == ErrNotFound {
+			return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		data, databaseError := exportSubscriberData(database, repositories, subscriber)
+
+		if databaseError != nil {
+			return databaseError
+		}
+
+		context.Response().Header().Set("Content-Disposition", `attachment; filename="subscriber-`+subscriber.Uuid+`.json"`)
+		return context.JSON(http.StatusOK, data)
+	})
+}
+
+func deleteSubscriber(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		uuid := context.Param("uuid")
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
+
+		if databaseError == ErrNotFound {
+			return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		if databaseError = eraseSubscriber(database, repositories, subscriber); databaseError != nil {
+			return databaseError
+		} else {
+			publishEvent(database, repositories.Clock, EventSubscriberUnsubscribed, SubscriberEvent{Uuid: subscriber.Uuid})
+			return context.NoContent(http.StatusNoContent)
+		}
+	})
+}
+
+func getAuditTrail(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		var entries []AuditEntry
+
+		if databaseError := database.All(&entries); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, entries)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestEraseTenantSubscriber(t *testing.T) {
+	directory, directoryError := ioutil.TempDir("", "mutservice-erasure-")
+
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+
+	defer os.RemoveAll(directory)
+
+	database, databaseError := openDatabase(filepath.Join(directory, "tenant.db"), systemClock{})
+
+	if databaseError != nil {
+		t.Fatal(databaseError)
+	}
+
+	defer database.Close()
+
+	repositories := newStormRepositories(database)
+	repositories.Organization = &Organization{Id: "acme", Name: "Acme"}
+	subscriber := Subscriber{Email: "erased@example.com", Uuid: "erased-uuid", Status: SubscriberActive}
+	key := createKey(repositories.Organization)
+
+	if databaseError = repositories.Subscribers.Save(&subscriber); databaseError != nil {
+		t.Fatal(databaseError)
+	}
+
+	saveMoodsOfDay(t, repositories.Moods, "01-06-2026", 1)
+
+	if databaseError = repositories.FeedbackKeys.Save(&FeedbackIdentifier{Key: key, DateString: "01-06-2026", SubscriberUuid: subscriber.Uuid}); databaseError != nil {
+		t.Fatal(databaseError)
+	}
+
+	if databaseError = eraseSubscriber(database, repositories, &subscriber); databaseError != nil {
+		t.Fatal(databaseError)
+	}
+
+	_, databaseError = repositories.FeedbackKeys.ByKey(key)
+	expect(t, databaseError == ErrNotFound, "the scoped feedback key of an erased tenant subscriber must be removed, got %v", databaseError)
+}
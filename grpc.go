@@ -0,0 +1,691 @@
+//go:build grpc
+// +build grpc
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"github.com/asdine/storm"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+	"log"
+	"mutservice/mutpb"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type grpcServer struct {
+	mutpb.UnimplementedMutServer
+	database     *storm.DB
+	repositories Repositories
+	tenants      *Tenants
+}
+
+var GrpcBind string = getEnvString("MUT_GRPC_BIND", ":9081")
+
+const grpcOrganizationHeader = "organization"
+
+var grpcAdminMethods = map[string]bool{
+	mutpb.Mut_ListSubscribers_FullMethodName:  true,
+	mutpb.Mut_GetSubscriber_FullMethodName:    true,
+	mutpb.Mut_DeleteSubscriber_FullMethodName: true,
+	mutpb.Mut_ActivateSurvey_FullMethodName:   true,
+	mutpb.Mut_GetSurveyResults_FullMethodName: true,
+	mutpb.Mut_WatchVotes_FullMethodName:       true,
+}
+
+var grpcLimitedMethods = map[string]bool{
+	mutpb.Mut_CreateSubscriber_FullMethodName: true,
+	mutpb.Mut_GetBallot_FullMethodName:        true,
+	mutpb.Mut_Vote_FullMethodName:             true,
+}
+
+var grpcBannedMethods = map[string]bool{
+	mutpb.Mut_GetBallot_FullMethodName: true,
+	mutpb.Mut_Vote_FullMethodName:      true,
+}
+
+func serveGrpc(bind string, database *storm.DB, repositories Repositories, tenants *Tenants) {
+	limiter := newRateLimiter(RateLimitPerMinute, RateLimitBurst, repositories.Clock)
+	banList := newBanList(BanThreshold, BanWindow, BanDuration, repositories.Clock)
+	mut := &grpcServer{database: database, repositories: repositories, tenants: tenants}
+
+	options := []grpc.ServerOption{
+		grpc.UnaryInterceptor(grpcUnaryAuth(mut, limiter, banList)),
+		grpc.StreamInterceptor(grpcStreamAuth(mut, limiter, banList)),
+	}
+
+	if isTLSEnabled() {
+		reloader, reloadError := newCertificateReloader(TLSCertFile, TLSKeyFile)
+
+		if reloadError != nil {
+			log.Fatal(reloadError)
+		}
+
+		go reloader.Watch(30 * time.Second)
+
+		config := &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+		options = append(options, grpc.Creds(credentials.NewTLS(config)))
+	}
+
+	listener, listenError := net.Listen("tcp", bind)
+
+	if listenError != nil {
+		log.Fatal(listenError)
+	}
+
+	server := grpc.NewServer(options...)
+	mutpb.RegisterMutServer(server, mut)
+
+	log.Println("Starting gRPC server on bind " + bind + ".")
+	log.Fatal(server.Serve(listener))
+}
+
+func getGrpcMetadata(requestContext context.Context, key string) string {
+	if incoming, found := metadata.FromIncomingContext(requestContext); found {
+		if values := incoming.Get(key); len(values) > 0 {
+			return values[0]
+		}
+	}
+	return ""
+}
+
+func (server *grpcServer) scope(requestContext context.Context, key string) (*storm.DB, Repositories, error) {
+	organizationId := getGrpcMetadata(requestContext, grpcOrganizationHeader)
+
+	if organizationId == "" {
+		organizationId, _ = splitScopedKey(key)
+	}
+
+	if organizationId == "" {
+		return server.database, server.repositories, nil
+	}
+
+	if tenant := server.tenants.Get(organizationId); tenant != nil {
+		return tenant.database, tenant.repositories, nil
+	}
+	return nil, Repositories{}, status.Error(codes.NotFound, "Organization with id '"+organizationId+"' not found!")
+}
+
+func getPeerIp(requestContext context.Context) string {
+	if TrustProxy {
+		if incoming, found := metadata.FromIncomingContext(requestContext); found {
+			if forwarded := incoming.Get("x-forwarded-for"); len(forwarded) > 0 && forwarded[0] != "" {
+				return strings.TrimSpace(strings.Split(forwarded[0], ",")[0])
+			}
+		}
+	}
+
+	client, found := peer.FromContext(requestContext)
+
+	if !found {
+		return ""
+	}
+
+	address := client.Addr.String()
+
+	if host, _, splitError := net.SplitHostPort(address); splitError == nil {
+		return host
+	}
+	return address
+}
+
+func authorizeGrpc(server *grpcServer, requestContext context.Context, method string, limiter *RateLimiter, banList *BanList) error {
+	ip := getPeerIp(requestContext)
+
+	if grpcBannedMethods[method] {
+		if wait := banList.BannedFor(ip); wait > 0 {
+			return status.Errorf(codes.PermissionDenied, "%s (retry after %s seconds)", translate(DefaultLocale, "rate.banned"), retryAfterSeconds(wait))
+		}
+	}
+
+	if grpcLimitedMethods[method] {
+		if allowed, _, wait := limiter.Take(ip + " " + method); !allowed {
+			return status.Errorf(codes.ResourceExhausted, "%s (retry after %s seconds)", translate(DefaultLocale, "rate.limited"), retryAfterSeconds(wait))
+		}
+	}
+
+	if !grpcAdminMethods[method] {
+		return nil
+	}
+
+	database, _, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return scopeError
+	}
+
+	given := getGrpcMetadata(requestContext, "authorization")
+
+	if isOrganizationToken(database, given) {
+		return nil
+	}
+
+	if AdminToken == "" {
+		return status.Error(codes.PermissionDenied, "Admin routes are disabled!")
+	}
+
+	if subtle.ConstantTimeCompare([]byte("Bearer "+AdminToken), []byte(given)) != 1 {
+		return status.Error(codes.Unauthenticated, "Invalid admin token!")
+	}
+
+	return nil
+}
+
+func grpcUnaryAuth(server *grpcServer, limiter *RateLimiter, banList *BanList) grpc.UnaryServerInterceptor {
+	return func(requestContext context.Context, request interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		if authError := authorizeGrpc(server, requestContext, info.FullMethod, limiter, banList); authError != nil {
+			return nil, authError
+		}
+
+		response, handlerError := handler(requestContext, request)
+
+		if grpcBannedMethods[info.FullMethod] && status.Code(handlerError) == codes.NotFound {
+			banList.RecordFailure(getPeerIp(requestContext))
+		}
+
+		return response, handlerError
+	}
+}
+
+func grpcStreamAuth(server *grpcServer, limiter *RateLimiter, banList *BanList) grpc.StreamServerInterceptor {
+	return func(service interface{}, stream grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if authError := authorizeGrpc(server, stream.Context(), info.FullMethod, limiter, banList); authError != nil {
+			return authError
+		}
+
+		return handler(service, stream)
+	}
+}
+
+func toGrpcError(err error) error {
+	switch invalid := err.(type) {
+	case nil:
+		return nil
+	case *ProfileError:
+		return status.Error(codes.InvalidArgument, invalid.Message)
+	case *AnswerError:
+		return status.Error(codes.InvalidArgument, invalid.Message)
+	case *paginationError:
+		return status.Error(codes.InvalidArgument, strings.Replace(invalid.message, "Query parameter", "Field", 1))
+	}
+
+	log.Printf("%s", err)
+	return status.Error(codes.Internal, "Internal error!")
+}
+
+func toTimestamp(value time.Time) *timestamppb.Timestamp {
+	if value.IsZero() {
+		return nil
+	}
+	return timestamppb.New(value)
+}
+
+func parseGrpcPage(limit int32, cursor string, descending bool, defaultLimit int, maximumLimit int) (page Page, parseError error) {
+	page.Limit = defaultLimit
+	page.Reverse = descending
+
+	if limit != 0 {
+		if page.Limit = int(limit); page.Limit < 1 || page.Limit > maximumLimit {
+			return page, &paginationError{"Query parameter 'limit' must be between 1 and " + strconv.Itoa(maximumLimit) + "!"}
+		}
+	}
+
+	if cursor != "" {
+		page.Cursor, parseError = decodeCursor(cursor)
+	}
+
+	return page, parseError
+}
+
+func nextCursor(next string) string {
+	if next == "" {
+		return ""
+	}
+	return encodeCursor(next)
+}
+
+func toSubscriberMessage(subscriber *Subscriber) *mutpb.Subscriber {
+	return &mutpb.Subscriber{
+		Uuid:        subscriber.Uuid,
+		Email:       subscriber.Email,
+		Team:        subscriber.Team,
+		Status:      subscriber.Status,
+		Name:        subscriber.Name,
+		Locale:      subscriber.Locale,
+		TimeZone:    subscriber.TimeZone,
+		Channel:     subscriber.Channel,
+		Attributes:  subscriber.Attributes,
+		NoReminders: subscriber.NoReminders,
+		PausedFrom:  subscriber.PausedFrom,
+		PausedUntil: subscriber.PausedUntil,
+		Frequency:   subscriber.Frequency,
+		Weekdays:    subscriber.Weekdays,
+		CreatedAt:   toTimestamp(subscriber.CreatedAt),
+		UpdatedAt:   toTimestamp(subscriber.UpdatedAt),
+	}
+}
+
+func toSurveyMessage(survey *Survey) *mutpb.Survey {
+	message := &mutpb.Survey{
+		Id:        survey.Id,
+		Version:   int32(survey.Version),
+		Name:      survey.Name,
+		Subject:   survey.Subject,
+		CreatedAt: toTimestamp(survey.CreatedAt),
+	}
+
+	for _, question := range survey.Questions {
+		message.Questions = append(message.Questions, &mutpb.Question{
+			Id:       question.Id,
+			Text:     question.Text,
+			Type:     question.Type,
+			Scale:    question.Scale,
+			Choices:  question.Choices,
+			Required: question.Required,
+			Order:    int32(question.Order),
+		})
+	}
+
+	return message
+}
+
+func toSurveyResultsMessage(results SurveyResults) *mutpb.SurveyResults {
+	message := &mutpb.SurveyResults{
+		Date:    results.DateString,
+		Survey:  results.SurveyId,
+		Version: int32(results.SurveyVersion),
+		Answers: map[string]*mutpb.QuestionAggregate{},
+	}
+
+	for id, aggregate := range results.Answers {
+		counts := map[string]int32{}
+		for answer, count := range aggregate.Counts {
+			counts[answer] = int32(count)
+		}
+
+		message.Answers[id] = &mutpb.QuestionAggregate{
+			Answered:   int32(aggregate.Answered),
+			Counts:     counts,
+			Texts:      aggregate.Texts,
+			Suppressed: aggregate.Suppressed,
+		}
+	}
+
+	return message
+}
+
+func toMoodsMessage(published PublishedMoods) *mutpb.Moods {
+	message := &mutpb.Moods{
+		Date:          published.DateString,
+		Survey:        published.SurveyId,
+		SurveyVersion: int32(published.SurveyVersion),
+		Scale:         published.Scale.Name,
+		Counts:        map[int32]int32{},
+		Weights:       map[int32]float64{},
+		Invited:       int32(published.Invited),
+		Paused:        int32(published.Paused),
+		Period:        published.Period,
+		Until:         published.Until,
+		WeightedMean:  published.WeightedMean,
+	}
+
+	for value, count := range published.Counts {
+		message.Counts[int32(value)] = int32(count)
+	}
+	for value, weight := range published.Weights {
+		message.Weights[int32(value)] = weight
+	}
+
+	return message
+}
+
+func (server *grpcServer) ListSubscribers(requestContext context.Context, request *mutpb.ListSubscribersRequest) (*mutpb.ListSubscribersResponse, error) {
+	_, repositories, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	page, parseError := parseGrpcPage(request.Limit, request.Cursor, request.Descending, SubscriberPageLimit, SubscriberPageMaximum)
+
+	if parseError != nil {
+		return nil, toGrpcError(parseError)
+	}
+
+	filter := SubscriberFilter{request.EmailPrefix, request.Status}
+	subscribers, next, total, databaseError := getSubscribersPage(repositories.Subscribers, filter, page)
+
+	if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	response := &mutpb.ListSubscribersResponse{NextCursor: nextCursor(next), Total: int32(total)}
+
+	for index := range subscribers {
+		response.Subscribers = append(response.Subscribers, toSubscriberMessage(&subscribers[index]))
+	}
+
+	return response, nil
+}
+
+func (server *grpcServer) GetSubscriber(requestContext context.Context, request *mutpb.GetSubscriberRequest) (*mutpb.Subscriber, error) {
+	_, repositories, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, request.Uuid)
+
+	if databaseError == ErrNotFound {
+		return nil, status.Error(codes.NotFound, "User with uuid '"+request.Uuid+"' not found!")
+	} else if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	return toSubscriberMessage(subscriber), nil
+}
+
+func (server *grpcServer) CreateSubscriber(requestContext context.Context, request *mutpb.CreateSubscriberRequest) (*mutpb.Subscriber, error) {
+	database, repositories, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	subscription := &Subscription{
+		Email:       request.Email,
+		Team:        request.Team,
+		Name:        request.Name,
+		Locale:      request.Locale,
+		TimeZone:    request.TimeZone,
+		Channel:     request.Channel,
+		Attributes:  request.Attributes,
+		NoReminders: request.NoReminders,
+		Frequency:   request.Frequency,
+		Weekdays:    request.Weekdays,
+	}
+
+	subscriber, databaseError := saveSubscriber(repositories, subscription)
+
+	if databaseError == ErrDuplicate {
+		return nil, status.Error(codes.AlreadyExists, "User with email '"+subscription.Email+"' already exists!")
+	} else if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	publishEvent(database, repositories.Clock, EventSubscriberCreated, SubscriberEvent{subscriber.Uuid, subscriber.Team})
+	return toSubscriberMessage(&subscriber), nil
+}
+
+func (server *grpcServer) DeleteSubscriber(requestContext context.Context, request *mutpb.DeleteSubscriberRequest) (*mutpb.DeleteSubscriberResponse, error) {
+	database, repositories, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, request.Uuid)
+
+	if databaseError == ErrNotFound {
+		return nil, status.Error(codes.NotFound, "User with uuid '"+request.Uuid+"' not found!")
+	} else if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	if databaseError = eraseSubscriber(database, repositories, subscriber); databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	publishEvent(database, repositories.Clock, EventSubscriberUnsubscribed, SubscriberEvent{Uuid: subscriber.Uuid})
+	return &mutpb.DeleteSubscriberResponse{}, nil
+}
+
+func (server *grpcServer) ListSurveys(requestContext context.Context, request *mutpb.ListSurveysRequest) (*mutpb.ListSurveysResponse, error) {
+	database, _, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	surveys, databaseError := getLatestSurveys(database)
+
+	if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	response := &mutpb.ListSurveysResponse{}
+
+	for index := range surveys {
+		response.Surveys = append(response.Surveys, toSurveyMessage(&surveys[index]))
+	}
+
+	return response, nil
+}
+
+func (server *grpcServer) GetSurvey(requestContext context.Context, request *mutpb.GetSurveyRequest) (*mutpb.Survey, error) {
+	database, _, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	var survey *Survey
+	var databaseError error
+
+	if request.Version != 0 {
+		survey, databaseError = getSurvey(database, request.Id, int(request.Version))
+	} else {
+		survey, databaseError = getLatestSurvey(database, request.Id)
+	}
+
+	if databaseError == storm.ErrNotFound {
+		return nil, status.Error(codes.NotFound, "Survey with id '"+request.Id+"' not found!")
+	} else if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	return toSurveyMessage(survey), nil
+}
+
+func (server *grpcServer) ActivateSurvey(requestContext context.Context, request *mutpb.ActivateSurveyRequest) (*mutpb.ActivateSurveyResponse, error) {
+	database, _, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	if _, databaseError := getLatestSurvey(database, request.Id); databaseError == storm.ErrNotFound {
+		return nil, status.Error(codes.NotFound, "Survey with id '"+request.Id+"' not found!")
+	} else if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	if databaseError := database.Set(settingsBucket, activeSurveyKey, request.Id); databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	return &mutpb.ActivateSurveyResponse{}, nil
+}
+
+func (server *grpcServer) GetSurveyResults(requestContext context.Context, request *mutpb.GetSurveyResultsRequest) (*mutpb.GetSurveyResultsResponse, error) {
+	database, _, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	var results []SurveyResults
+
+	if databaseError := database.Find("SurveyId", request.Id, &results); databaseError != nil && databaseError != storm.ErrNotFound {
+		return nil, toGrpcError(databaseError)
+	}
+
+	response := &mutpb.GetSurveyResultsResponse{}
+
+	for _, result := range results {
+		response.Results = append(response.Results, toSurveyResultsMessage(publishSurveyResults(result, true)))
+	}
+
+	return response, nil
+}
+
+func (server *grpcServer) GetBallot(requestContext context.Context, request *mutpb.GetBallotRequest) (*mutpb.Ballot, error) {
+	database, repositories, scopeError := server.scope(requestContext, request.Key)
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	feedbackIdentifier := getFeedbackIdentifier(repositories.FeedbackKeys, request.Key)
+
+	if feedbackIdentifier == nil {
+		return nil, status.Error(codes.NotFound, translate(DefaultLocale, "form.not-found", request.Key))
+	}
+
+	dailyMoods, databaseError := getDailyMoodsByDate(repositories.Moods, feedbackIdentifier.DateString)
+
+	if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	survey, databaseError := getSurveyOfDay(database, dailyMoods)
+
+	if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	return &mutpb.Ballot{Date: feedbackIdentifier.DateString, Survey: toSurveyMessage(survey)}, nil
+}
+
+func (server *grpcServer) Vote(requestContext context.Context, request *mutpb.VoteRequest) (*mutpb.VoteResponse, error) {
+	database, repositories, scopeError := server.scope(requestContext, request.Key)
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	feedbackIdentifier := getFeedbackIdentifier(repositories.FeedbackKeys, request.Key)
+
+	if feedbackIdentifier == nil {
+		return nil, status.Error(codes.NotFound, translate(DefaultLocale, "form.not-found", request.Key))
+	}
+
+	answers := Answers{}
+	for id, values := range request.Answers {
+		answers[id] = values.GetValues()
+	}
+
+	if databaseError := recordSurveyAnswers(database, repositories, feedbackIdentifier, answers, ChannelGrpc); databaseError == ErrNotFound {
+		return nil, status.Error(codes.NotFound, translate(DefaultLocale, "form.not-found", request.Key))
+	} else if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	return &mutpb.VoteResponse{}, nil
+}
+
+func (server *grpcServer) ListMoods(requestContext context.Context, request *mutpb.ListMoodsRequest) (*mutpb.ListMoodsResponse, error) {
+	_, repositories, scopeError := server.scope(requestContext, "")
+
+	if scopeError != nil {
+		return nil, scopeError
+	}
+
+	page, parseError := parseGrpcPage(request.Limit, request.Cursor, request.Descending, MoodPageMonths, MoodPageMaximumMonths)
+
+	if parseError != nil {
+		return nil, toGrpcError(parseError)
+	}
+
+	filter := MoodFilter{}
+	for name, field := range map[string]string{"from": request.From, "to": request.To} {
+		if field == "" {
+			continue
+		}
+
+		date, dateError := time.Parse(dateLayout, field)
+
+		if dateError != nil {
+			return nil, status.Error(codes.InvalidArgument, "Field '"+name+"' must be a date like "+dateLayout+"!")
+		}
+
+		if name == "from" {
+			filter.From = date
+		} else {
+			filter.To = date
+		}
+	}
+
+	published, next, databaseError := getPublishedMoodsPage(repositories.Moods, filter, page)
+
+	if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	total, databaseError := repositories.Moods.Count(filter.bounds())
+
+	if databaseError != nil {
+		return nil, toGrpcError(databaseError)
+	}
+
+	response := &mutpb.ListMoodsResponse{NextCursor: nextCursor(next), Total: int32(total)}
+
+	for _, moods := range published {
+		response.Moods = append(response.Moods, toMoodsMessage(moods))
+	}
+
+	return response, nil
+}
+
+func (server *grpcServer) WatchVotes(request *mutpb.WatchVotesRequest, stream grpc.ServerStreamingServer[mutpb.VoteEvent]) error {
+	database, _, scopeError := server.scope(stream.Context(), "")
+
+	if scopeError != nil {
+		return scopeError
+	}
+
+	hub := getEventHub(database)
+	events := hub.Subscribe()
+	defer hub.Unsubscribe(events)
+
+	for {
+		select {
+		case <-stream.Context().Done():
+			return nil
+		case event, open := <-events:
+			if !open {
+				return status.Error(codes.ResourceExhausted, "Client is too slow to keep up with the vote stream!")
+			}
+
+			vote, isVote := event.Data.(VoteRecordedEvent)
+
+			if !isVote {
+				continue
+			}
+
+			message := &mutpb.VoteEvent{
+				Id:            event.Id,
+				Date:          vote.DateString,
+				Survey:        vote.SurveyId,
+				SurveyVersion: int32(vote.SurveyVersion),
+				Channel:       vote.Channel,
+				OccurredAt:    toTimestamp(event.OccurredAt),
+			}
+
+			if sendError := stream.Send(message); sendError != nil {
+				return sendError
+			}
+		}
+	}
+}
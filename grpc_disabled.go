@@ -0,0 +1,17 @@
+//go:build !grpc
+// +build !grpc
+
+package main
+
+import (
+	"github.com/asdine/storm"
+	"log"
+)
+
+var GrpcBind string = getEnvString("MUT_GRPC_BIND", "")
+
+func serveGrpc(bind string, database *storm.DB, repositories Repositories, tenants *Tenants) {
+	if bind != "" {
+		log.Println("Ignoring MUT_GRPC_BIND " + bind + ", this build has no gRPC server. Build with '-tags grpc' to serve it.")
+	}
+}
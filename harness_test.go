@@ -0,0 +1,373 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo/engine"
+	"github.com/labstack/echo/engine/fasthttp"
+	"github.com/robfig/cron"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+)
+
+type (
+	Harness struct {
+		Clock        *FakeClock
+		Scheduler    *ManualScheduler
+		Mailer       *CapturingMailer
+		Database     *storm.DB
+		Repositories Repositories
+		Url          string
+		directory    string
+		listener     net.Listener
+	}
+
+	FakeClock struct {
+		lock sync.RWMutex
+		now  time.Time
+	}
+
+	ManualScheduler struct {
+		lock  sync.Mutex
+		clock *FakeClock
+		jobs  []*manualJob
+	}
+
+	manualJob struct {
+		schedule cron.Schedule
+		next     time.Time
+		run      func()
+	}
+
+	CapturingMailer struct {
+		lock     sync.Mutex
+		clock    Clock
+		messages []CapturedMail
+	}
+
+	CapturedMail struct {
+		Email   string
+		Subject string
+		Text    string
+		SentAt  time.Time
+	}
+)
+
+var feedbackLinkPattern = regexp.MustCompile(`/moods/([0-9a-f]{40})`)
+
+func newFakeClock(start time.Time) *FakeClock {
+	return &FakeClock{now: start}
+}
+
+func (fake *FakeClock) Now() time.Time {
+	fake.lock.RLock()
+	defer fake.lock.RUnlock()
+
+	return fake.now
+}
+
+func (fake *FakeClock) Set(now time.Time) {
+	fake.lock.Lock()
+	defer fake.lock.Unlock()
+
+	fake.now = now
+}
+
+func newManualScheduler(fake *FakeClock) *ManualScheduler {
+	return &ManualScheduler{clock: fake}
+}
+
+func (scheduler *ManualScheduler) Schedule(spec string, commands ...func()) error {
+	schedule, parseError := cron.Parse(spec)
+
+	if parseError != nil {
+		return parseError
+	}
+
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	scheduler.jobs = append(scheduler.jobs, &manualJob{schedule, schedule.Next(scheduler.clock.Now()), runAll(commands)})
+	return nil
+}
+
+func (scheduler *ManualScheduler) Start() {
+}
+
+func (scheduler *ManualScheduler) Stop() {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	scheduler.jobs = nil
+}
+
+func (scheduler *ManualScheduler) AdvanceTo(target time.Time) {
+	for {
+		job := scheduler.nextDueJob(target)
+
+		if job == nil {
+			break
+		}
+
+		scheduler.clock.Set(job.next)
+		job.next = job.schedule.Next(job.next)
+		job.run()
+	}
+
+	scheduler.clock.Set(target)
+}
+
+func (scheduler *ManualScheduler) nextDueJob(target time.Time) *manualJob {
+	scheduler.lock.Lock()
+	defer scheduler.lock.Unlock()
+
+	sort.SliceStable(scheduler.jobs, func(i, j int) bool {
+		return scheduler.jobs[i].next.Before(scheduler.jobs[j].next)
+	})
+
+	if len(scheduler.jobs) == 0 || scheduler.jobs[0].next.After(target) {
+		return nil
+	}
+	return scheduler.jobs[0]
+}
+
+func (capturing *CapturingMailer) Send(message MailMessage) error {
+	capturing.lock.Lock()
+	defer capturing.lock.Unlock()
+
+	capturing.messages = append(capturing.messages, CapturedMail{message.To, message.Subject, message.Html + message.Text, capturing.clock.Now()})
+	return nil
+}
+
+func (capturing *CapturingMailer) Take() (messages []CapturedMail) {
+	capturing.lock.Lock()
+	defer capturing.lock.Unlock()
+
+	messages, capturing.messages = capturing.messages, nil
+	return messages
+}
+
+func (mail CapturedMail) FeedbackKey() string {
+	if match := feedbackLinkPattern.FindStringSubmatch(mail.Text); match != nil {
+		return match[1]
+	}
+	return ""
+}
+
+func newHarness(t *testing.T, start time.Time) *Harness {
+	harness := &Harness{Clock: newFakeClock(start)}
+	harness.Scheduler = newManualScheduler(harness.Clock)
+	harness.Mailer = &CapturingMailer{clock: harness.Clock}
+	t.Cleanup(harness.Close)
+
+	var harnessError error
+
+	if harness.directory, harnessError = ioutil.TempDir("", "mutservice-harness-"); harnessError != nil {
+		t.Fatal(harnessError)
+	}
+
+	if harness.Database, harnessError = openDatabase(filepath.Join(harness.directory, "app-mut.db"), harness.Clock); harnessError != nil {
+		t.Fatal(harnessError)
+	}
+
+	harness.Repositories = newStormRepositories(harness.Database)
+	harness.Repositories.Clock = harness.Clock
+	harness.Repositories.Mailer = harness.Mailer
+
+	if harnessError = scheduleJobs(harness.Scheduler, harness.Database, harness.Repositories); harnessError != nil {
+		t.Fatal(harnessError)
+	}
+
+	if harness.listener, harnessError = net.Listen("tcp", "127.0.0.1:0"); harnessError != nil {
+		t.Fatal(harnessError)
+	}
+
+	harness.Url = "http://" + harness.listener.Addr().String()
+	server := initServer(harness.Database, harness.Repositories)
+	go server.Run(fasthttp.WithConfig(engine.Config{Listener: harness.listener}))
+
+	return harness
+}
+
+func (harness *Harness) Close() {
+	if harness.listener != nil {
+		harness.listener.Close()
+	}
+
+	if harness.Database != nil {
+		harness.Database.Close()
+	}
+
+	if harness.directory != "" {
+		os.RemoveAll(harness.directory)
+	}
+}
+
+func (harness *Harness) AdvanceDays(days int) {
+	harness.Scheduler.AdvanceTo(harness.Clock.Now().AddDate(0, 0, days))
+}
+
+func (harness *Harness) Subscribe(t *testing.T, email string) (subscriber Subscriber) {
+	body, _ := json.Marshal(Subscription{Email: email})
+	response, harnessError := http.Post(harness.Url+"/subscribers", "application/json", bytes.NewReader(body))
+
+	if harnessError != nil {
+		t.Fatal(harnessError)
+	}
+
+	defer response.Body.Close()
+
+	if response.StatusCode != http.StatusCreated {
+		t.Fatalf("subscribing %s answered with status %d", email, response.StatusCode)
+	}
+
+	if harnessError = json.NewDecoder(response.Body).Decode(&subscriber); harnessError != nil {
+		t.Fatal(harnessError)
+	}
+	return subscriber
+}
+
+func (harness *Harness) Vote(t *testing.T, key string, mood string) (status int) {
+	response, harnessError := http.PostForm(harness.Url+"/moods/"+key, url.Values{MoodQuestionId: {mood}})
+
+	if harnessError != nil {
+		t.Fatal(harnessError)
+	}
+
+	defer response.Body.Close()
+	return response.StatusCode
+}
+
+func (harness *Harness) DailyMoods(t *testing.T, date time.Time) *DailyMoods {
+	dailyMoods, databaseError := harness.Repositories.Moods.ByDate(date.Format(dateLayout))
+
+	if databaseError != nil {
+		t.Fatalf("moods of %s: %s", date.Format(dateLayout), databaseError)
+	}
+	return dailyMoods
+}
+
+func (harness *Harness) SimulateDays(t *testing.T, days int, vote func(day int, email string) string) {
+	for day := 0; day < days; day++ {
+		harness.AdvanceDays(1)
+
+		for _, mail := range harness.Mailer.Take() {
+			key := mail.FeedbackKey()
+			mood := vote(day, mail.Email)
+
+			if key == "" || mood == "" {
+				continue
+			}
+
+			if status := harness.Vote(t, key, mood); status != http.StatusCreated {
+				t.Fatalf("vote of %s on day %d answered with status %d", mail.Email, day, status)
+			}
+		}
+	}
+}
+
+func subscribeAll(t *testing.T, harness *Harness, count int) {
+	for index := 0; index < count; index++ {
+		harness.Subscribe(t, "person-"+strconv.Itoa(index)+"@example.com")
+	}
+}
+
+func TestSimulatedWeeks(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, time.March, 2, 0, 0, 0, 0, time.UTC)
+	harness := newHarness(t, start)
+	subscribeAll(t, harness, 5)
+
+	harness.SimulateDays(t, 21, func(day int, email string) string {
+		if email == "person-0@example.com" {
+			return ""
+		}
+		return strconv.Itoa(DefaultScale.Points[day%len(DefaultScale.Points)].Value)
+	})
+
+	for day := 0; day < 21; day++ {
+		dailyMoods := harness.DailyMoods(t, start.AddDate(0, 0, day))
+
+		expect(t, dailyMoods.Invited == 5, "%s: invited %d, want 5", dailyMoods.DateString, dailyMoods.Invited)
+		expect(t, dailyMoods.Total() == 4, "%s: counted %d votes, want 4", dailyMoods.DateString, dailyMoods.Total())
+	}
+}
+
+func TestNextDayRollover(t *testing.T) {
+	t.Parallel()
+
+	start := time.Date(2026, time.June, 1, 0, 0, 0, 0, time.UTC)
+	harness := newHarness(t, start)
+	subscribeAll(t, harness, 2)
+
+	harness.AdvanceDays(1)
+	first := harness.Mailer.Take()
+	harness.AdvanceDays(1)
+	second := harness.Mailer.Take()
+
+	if len(first) != 2 || len(second) != 2 {
+		t.Fatalf("expected two mails per day, got %d and %d", len(first), len(second))
+	}
+
+	expect(t, first[0].FeedbackKey() != second[0].FeedbackKey(), "the next day must send a new feedback key")
+	expect(t, harness.Vote(t, first[0].FeedbackKey(), "1") == http.StatusCreated, "a late vote on yesterday's key must still be accepted")
+	expect(t, harness.Vote(t, first[0].FeedbackKey(), "1") == http.StatusNotFound, "a feedback key must only be used once")
+	expect(t, harness.Vote(t, second[1].FeedbackKey(), "2") == http.StatusCreated, "a vote on today's key must be accepted")
+
+	yesterday := harness.DailyMoods(t, start)
+	today := harness.DailyMoods(t, start.AddDate(0, 0, 1))
+
+	expect(t, yesterday.Total() == 1 && yesterday.Counts[1] == 1, "the late vote must be counted on the day it was sent, got %v", yesterday.Counts)
+	expect(t, today.Total() == 1 && today.Counts[2] == 1, "today's vote must be counted on today, got %v", today.Counts)
+}
+
+func TestDaylightSavingSwitch(t *testing.T) {
+	location, locationError := time.LoadLocation("Europe/Berlin")
+
+	if locationError != nil {
+		t.Skip("time zone data is not available: ", locationError)
+	}
+
+	for name, start := range map[string]time.Time{
+		"spring": time.Date(2026, time.March, 27, 0, 0, 0, 0, location),
+		"autumn": time.Date(2026, time.October, 23, 0, 0, 0, 0, location),
+	} {
+		start := start
+
+		t.Run(name, func(t *testing.T) {
+			t.Parallel()
+
+			harness := newHarness(t, start)
+			subscribeAll(t, harness, 2)
+
+			for day := 0; day < 4; day++ {
+				harness.AdvanceDays(1)
+				mails := harness.Mailer.Take()
+				date := start.AddDate(0, 0, day)
+
+				if len(mails) != 2 {
+					t.Fatalf("%s: expected one mail per subscriber, got %d", date.Format(dateLayout), len(mails))
+				}
+
+				sentAt := mails[0].SentAt.In(location)
+				expect(t, sentAt.Hour() == 13 && sentAt.Minute() == 15, "%s: survey sent at %s, want 13:15 local time", date.Format(dateLayout), sentAt.Format("15:04"))
+				expect(t, harness.DailyMoods(t, date).Invited == 2, "%s: the day must be surveyed exactly once", date.Format(dateLayout))
+			}
+
+			all, databaseError := harness.Repositories.Moods.All()
+			expect(t, databaseError == nil && len(all) == 4, "expected four surveyed days, got %d", len(all))
+		})
+	}
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"github.com/asdine/storm"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	EventHub struct {
+		lock        sync.Mutex
+		epoch       string
+		sequence    uint64
+		history     []HubEvent
+		subscribers map[chan HubEvent]bool
+	}
+
+	HubEvent struct {
+		Sequence uint64
+		WebhookEvent
+	}
+)
+
+var EventHubBuffer int = getEnvInt("MUT_EVENT_HUB_BUFFER", 64)
+var EventHubHistory int = getEnvInt("MUT_EVENT_HUB_HISTORY", 256)
+
+var eventHubsLock sync.Mutex
+var eventHubs = map[*storm.DB]*EventHub{}
+
+func newEventHub() *EventHub {
+	return &EventHub{epoch: strconv.FormatInt(time.Now().UnixNano(), 36), subscribers: map[chan HubEvent]bool{}}
+}
+
+func getEventHub(database *storm.DB) *EventHub {
+	eventHubsLock.Lock()
+	defer eventHubsLock.Unlock()
+
+	hub, found := eventHubs[database]
+	if !found {
+		hub = newEventHub()
+		eventHubs[database] = hub
+	}
+	return hub
+}
+
+func (hub *EventHub) register() chan HubEvent {
+	events := make(chan HubEvent, EventHubBuffer)
+	hub.subscribers[events] = true
+	return events
+}
+
+func (hub *EventHub) Subscribe() chan HubEvent {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	return hub.register()
+}
+
+func (hub *EventHub) SubscribeAfter(sequence uint64) (events chan HubEvent, missed []HubEvent, complete bool) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	for _, event := range hub.history {
+		if event.Sequence > sequence {
+			missed = append(missed, event)
+		}
+	}
+
+	complete = sequence <= hub.sequence && uint64(len(missed)) == hub.sequence-sequence
+	return hub.register(), missed, complete
+}
+
+func (hub *EventHub) Unsubscribe(events chan HubEvent) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	if hub.subscribers[events] {
+		delete(hub.subscribers, events)
+		close(events)
+	}
+}
+
+func (hub *EventHub) Subscribers() int {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	return len(hub.subscribers)
+}
+
+func (hub *EventHub) Broadcast(webhookEvent WebhookEvent) {
+	hub.lock.Lock()
+	defer hub.lock.Unlock()
+
+	hub.sequence++
+	event := HubEvent{hub.sequence, webhookEvent}
+
+	if EventHubHistory > 0 {
+		if len(hub.history) >= EventHubHistory {
+			hub.history = hub.history[1:]
+		}
+		hub.history = append(hub.history, event)
+	}
+
+	for events := range hub.subscribers {
+		select {
+		case events <- event:
+		default:
+			delete(hub.subscribers, events)
+			close(events)
+		}
+	}
+}
+
+func (hub *EventHub) EventId(sequence uint64) string {
+	return hub.epoch + "-" + strconv.FormatUint(sequence, 10)
+}
+
+func (hub *EventHub) ParseEventId(id string) (sequence uint64, valid bool) {
+	separator := strings.LastIndex(id, "-")
+
+	if separator < 0 || id[:separator] != hub.epoch {
+		return 0, false
+	}
+
+	sequence, parseError := strconv.ParseUint(id[separator+1:], 10, 64)
+	return sequence, parseError == nil
+}
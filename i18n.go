@@ -0,0 +1,368 @@
+package main
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	Catalog struct {
+		Messages  map[string]string
+		Weekdays  [7]string
+		Months    [12]string
+		ShortDate string
+		LongDate  string
+	}
+
+	languagePreference struct {
+		tag     string
+		quality float64
+	}
+)
+
+var catalogs = map[string]Catalog{
+	"en": {
+		Messages: map[string]string{
+			"survey.subject":           "How is your mood today?",
+			"survey.name":              "Daily mood",
+			"survey.question":          "Select your mood",
+			"mail.greeting":            "How are you today?",
+			"mail.greeting.named":      "How are you today, %s?",
+			"mail.link":                "Take me to the survey!",
+			"reminder.subject":         "Reminder: How is your mood today?",
+			"reminder.greeting":        "You have not told us how you are today yet.",
+			"reminder.greeting.named":  "%s, you have not told us how you are today yet.",
+			"form.send":                "Send",
+			"form.thanks":              "Thank you!",
+			"form.not-found":           "Mood with key '%s' not found!",
+			"mail.preferences":         "Pause or resume surveys",
+			"pause.title":              "Survey preferences",
+			"pause.pause-week":         "Pause for a week",
+			"pause.pause-two-weeks":    "Pause for two weeks",
+			"pause.pause-indefinitely": "Pause until I resume",
+			"pause.pause-until":        "Pause until",
+			"pause.resume":             "Resume surveys",
+			"pause.state.active":       "You are receiving surveys.",
+			"pause.state.indefinite":   "Surveys are paused since %s.",
+			"pause.state.until":        "Surveys are paused from %s until %s.",
+			"pause.saved":              "Your preferences have been saved.",
+			"pause.invalid":            "Please choose a valid date!",
+			"pause.not-found":          "Preferences not found!",
+			"rate.limited":             "Too many requests, please retry later!",
+			"rate.banned":              "Too many unknown keys, please retry later!",
+			"alert.firing":             "firing",
+			"alert.resolved":           "resolved",
+			"alert.text":               "Rule '%s' (%s, threshold %.2f) is %s for %s with value %.2f.",
+			"scale.Miserable":          "Miserable",
+			"scale.Very unhappy":       "Very unhappy",
+			"scale.Unhappy":            "Unhappy",
+			"scale.Neutral":            "Neutral",
+			"scale.Happy":              "Happy",
+			"scale.Very happy":         "Very happy",
+			"scale.Excellent":          "Excellent",
+			"scale.Terrible":           "Terrible",
+			"scale.Very bad":           "Very bad",
+			"scale.Bad":                "Bad",
+			"scale.Poor":               "Poor",
+			"scale.Mediocre":           "Mediocre",
+			"scale.Okay":               "Okay",
+			"scale.Good":               "Good",
+			"scale.Very good":          "Very good",
+			"scale.Great":              "Great",
+		},
+		Weekdays:  [7]string{"Sunday", "Monday", "Tuesday", "Wednesday", "Thursday", "Friday", "Saturday"},
+		Months:    [12]string{"January", "February", "March", "April", "May", "June", "July", "August", "September", "October", "November", "December"},
+		ShortDate: "%[1]s, %[2]d %[3]s",
+		LongDate:  "%[1]s, %[2]d %[3]s %[4]d",
+	},
+	"de": {
+		Messages: map[string]string{
+			"survey.subject":           "Wie ist deine Stimmung heute?",
+			"survey.name":              "Tägliche Stimmung",
+			"survey.question":          "Wähle deine Stimmung",
+			"mail.greeting":            "Wie geht es dir heute?",
+			"mail.greeting.named":      "Wie geht es dir heute, %s?",
+			"mail.link":                "Zur Umfrage!",
+			"reminder.subject":         "Erinnerung: Wie ist deine Stimmung heute?",
+			"reminder.greeting":        "Du hast uns noch nicht gesagt, wie es dir heute geht.",
+			"reminder.greeting.named":  "%s, du hast uns noch nicht gesagt, wie es dir heute geht.",
+			"form.send":                "Absenden",
+			"form.thanks":              "Danke!",
+			"form.not-found":           "Stimmung mit dem Schlüssel '%s' nicht gefunden!",
+			"mail.preferences":         "Umfragen pausieren oder fortsetzen",
+			"pause.title":              "Umfrage-Einstellungen",
+			"pause.pause-week":         "Eine Woche pausieren",
+			"pause.pause-two-weeks":    "Zwei Wochen pausieren",
+			"pause.pause-indefinitely": "Pausieren, bis ich fortsetze",
+			"pause.pause-until":        "Pausieren bis",
+			"pause.resume":             "Umfragen fortsetzen",
+			"pause.state.active":       "Du erhältst Umfragen.",
+			"pause.state.indefinite":   "Umfragen sind seit %s pausiert.",
+			"pause.state.until":        "Umfragen sind von %s bis %s pausiert.",
+			"pause.saved":              "Deine Einstellungen wurden gespeichert.",
+			"pause.invalid":            "Bitte wähle ein gültiges Datum!",
+			"pause.not-found":          "Einstellungen nicht gefunden!",
+			"rate.limited":             "Zu viele Anfragen, bitte versuche es später erneut!",
+			"rate.banned":              "Zu viele unbekannte Schlüssel, bitte versuche es später erneut!",
+			"alert.firing":             "ausgelöst",
+			"alert.resolved":           "behoben",
+			"alert.text":               "Regel '%s' (%s, Schwellwert %.2f) ist %s für %s mit dem Wert %.2f.",
+			"scale.Miserable":          "Elend",
+			"scale.Very unhappy":       "Sehr unglücklich",
+			"scale.Unhappy":            "Unglücklich",
+			"scale.Neutral":            "Neutral",
+			"scale.Happy":              "Glücklich",
+			"scale.Very happy":         "Sehr glücklich",
+			"scale.Excellent":          "Ausgezeichnet",
+			"scale.Terrible":           "Schrecklich",
+			"scale.Very bad":           "Sehr schlecht",
+			"scale.Bad":                "Schlecht",
+			"scale.Poor":               "Dürftig",
+			"scale.Mediocre":           "Mittelmäßig",
+			"scale.Okay":               "In Ordnung",
+			"scale.Good":               "Gut",
+			"scale.Very good":          "Sehr gut",
+			"scale.Great":              "Großartig",
+		},
+		Weekdays:  [7]string{"Sonntag", "Montag", "Dienstag", "Mittwoch", "Donnerstag", "Freitag", "Samstag"},
+		Months:    [12]string{"Januar", "Februar", "März", "April", "Mai", "Juni", "Juli", "August", "September", "Oktober", "November", "Dezember"},
+		ShortDate: "%[1]s, %[2]d. %[3]s",
+		LongDate:  "%[1]s, %[2]d. %[3]s %[4]d",
+	},
+	"fr": {
+		Messages: map[string]string{
+			"survey.subject":           "Comment est votre humeur aujourd'hui ?",
+			"survey.name":              "Humeur du jour",
+			"survey.question":          "Choisissez votre humeur",
+			"mail.greeting":            "Comment allez-vous aujourd'hui ?",
+			"mail.greeting.named":      "Comment allez-vous aujourd'hui, %s ?",
+			"mail.link":                "Accéder au sondage !",
+			"reminder.subject":         "Rappel : comment est votre humeur aujourd'hui ?",
+			"reminder.greeting":        "Vous ne nous avez pas encore dit comment vous allez aujourd'hui.",
+			"reminder.greeting.named":  "%s, vous ne nous avez pas encore dit comment vous allez aujourd'hui.",
+			"form.send":                "Envoyer",
+			"form.thanks":              "Merci !",
+			"form.not-found":           "Humeur avec la clé '%s' introuvable !",
+			"mail.preferences":         "Suspendre ou reprendre les sondages",
+			"pause.title":              "Préférences des sondages",
+			"pause.pause-week":         "Suspendre une semaine",
+			"pause.pause-two-weeks":    "Suspendre deux semaines",
+			"pause.pause-indefinitely": "Suspendre jusqu'à nouvel ordre",
+			"pause.pause-until":        "Suspendre jusqu'au",
+			"pause.resume":             "Reprendre les sondages",
+			"pause.state.active":       "Vous recevez les sondages.",
+			"pause.state.indefinite":   "Les sondages sont suspendus depuis le %s.",
+			"pause.state.until":        "Les sondages sont suspendus du %s au %s.",
+			"pause.saved":              "Vos préférences ont été enregistrées.",
+			"pause.invalid":            "Veuillez choisir une date valide !",
+			"pause.not-found":          "Préférences introuvables !",
+			"rate.limited":             "Trop de requêtes, veuillez réessayer plus tard !",
+			"rate.banned":              "Trop de clés inconnues, veuillez réessayer plus tard !",
+			"alert.firing":             "déclenchée",
+			"alert.resolved":           "résolue",
+			"alert.text":               "La règle '%s' (%s, seuil %.2f) est %s pour le %s avec la valeur %.2f.",
+			"scale.Miserable":          "Misérable",
+			"scale.Very unhappy":       "Très malheureux",
+			"scale.Unhappy":            "Malheureux",
+			"scale.Neutral":            "Neutre",
+			"scale.Happy":              "Heureux",
+			"scale.Very happy":         "Très heureux",
+			"scale.Excellent":          "Excellent",
+			"scale.Terrible":           "Terrible",
+			"scale.Very bad":           "Très mauvais",
+			"scale.Bad":                "Mauvais",
+			"scale.Poor":               "Faible",
+			"scale.Mediocre":           "Médiocre",
+			"scale.Okay":               "Correct",
+			"scale.Good":               "Bien",
+			"scale.Very good":          "Très bien",
+			"scale.Great":              "Super",
+		},
+		Weekdays:  [7]string{"dimanche", "lundi", "mardi", "mercredi", "jeudi", "vendredi", "samedi"},
+		Months:    [12]string{"janvier", "février", "mars", "avril", "mai", "juin", "juillet", "août", "septembre", "octobre", "novembre", "décembre"},
+		ShortDate: "%[1]s %[2]d %[3]s",
+		LongDate:  "%[1]s %[2]d %[3]s %[4]d",
+	},
+	"es": {
+		Messages: map[string]string{
+			"survey.subject":           "¿Cómo está tu ánimo hoy?",
+			"survey.name":              "Ánimo diario",
+			"survey.question":          "Elige tu estado de ánimo",
+			"mail.greeting":            "¿Cómo estás hoy?",
+			"mail.greeting.named":      "¿Cómo estás hoy, %s?",
+			"mail.link":                "¡Ir a la encuesta!",
+			"reminder.subject":         "Recordatorio: ¿cómo está tu ánimo hoy?",
+			"reminder.greeting":        "Todavía no nos has dicho cómo estás hoy.",
+			"reminder.greeting.named":  "%s, todavía no nos has dicho cómo estás hoy.",
+			"form.send":                "Enviar",
+			"form.thanks":              "¡Gracias!",
+			"form.not-found":           "¡No se encontró el ánimo con la clave '%s'!",
+			"mail.preferences":         "Pausar o reanudar las encuestas",
+			"pause.title":              "Preferencias de las encuestas",
+			"pause.pause-week":         "Pausar una semana",
+			"pause.pause-two-weeks":    "Pausar dos semanas",
+			"pause.pause-indefinitely": "Pausar hasta que las reanude",
+			"pause.pause-until":        "Pausar hasta",
+			"pause.resume":             "Reanudar las encuestas",
+			"pause.state.active":       "Estás recibiendo encuestas.",
+			"pause.state.indefinite":   "Las encuestas están pausadas desde el %s.",
+			"pause.state.until":        "Las encuestas están pausadas del %s al %s.",
+			"pause.saved":              "Tus preferencias se han guardado.",
+			"pause.invalid":            "¡Elige una fecha válida!",
+			"pause.not-found":          "¡No se encontraron las preferencias!",
+			"rate.limited":             "Demasiadas solicitudes, inténtalo de nuevo más tarde.",
+			"rate.banned":              "Demasiadas claves desconocidas, inténtalo de nuevo más tarde.",
+			"alert.firing":             "activada",
+			"alert.resolved":           "resuelta",
+			"alert.text":               "La regla '%s' (%s, umbral %.2f) está %s para el %s con el valor %.2f.",
+			"scale.Miserable":          "Fatal",
+			"scale.Very unhappy":       "Muy infeliz",
+			"scale.Unhappy":            "Infeliz",
+			"scale.Neutral":            "Neutral",
+			"scale.Happy":              "Feliz",
+			"scale.Very happy":         "Muy feliz",
+			"scale.Excellent":          "Excelente",
+			"scale.Terrible":           "Terrible",
+			"scale.Very bad":           "Muy mal",
+			"scale.Bad":                "Mal",
+			"scale.Poor":               "Flojo",
+			"scale.Mediocre":           "Regular",
+			"scale.Okay":               "Aceptable",
+			"scale.Good":               "Bien",
+			"scale.Very good":          "Muy bien",
+			"scale.Great":              "Genial",
+		},
+		Weekdays:  [7]string{"domingo", "lunes", "martes", "miércoles", "jueves", "viernes", "sábado"},
+		Months:    [12]string{"enero", "febrero", "marzo", "abril", "mayo", "junio", "julio", "agosto", "septiembre", "octubre", "noviembre", "diciembre"},
+		ShortDate: "%[1]s, %[2]d de %[3]s",
+		LongDate:  "%[1]s, %[2]d de %[3]s de %[4]d",
+	},
+}
+
+var defaultSurveyTexts = map[string]string{
+	"How is your mood today?": "survey.subject",
+	"Daily mood":              "survey.name",
+	"Select your mood":        "survey.question",
+}
+
+var DefaultLocale string = getDefaultLocale()
+
+func getDefaultLocale() string {
+	tag := getEnvString("MUT_DEFAULT_LOCALE", "en")
+
+	if locale, found := resolveLocale(tag); found {
+		return locale
+	} else {
+		log.Printf("Unknown locale '%s', falling back to 'en'.", tag)
+		return "en"
+	}
+}
+
+func resolveLocale(tag string) (string, bool) {
+	tag = strings.ToLower(strings.Replace(strings.TrimSpace(tag), "_", "-", -1))
+
+	if _, found := catalogs[tag]; found {
+		return tag, true
+	}
+
+	if separator := strings.Index(tag, "-"); separator > 0 {
+		if _, found := catalogs[tag[:separator]]; found {
+			return tag[:separator], true
+		}
+	}
+
+	return "", false
+}
+
+func getLocale(tag string) string {
+	if locale, found := resolveLocale(tag); found {
+		return locale
+	} else {
+		return DefaultLocale
+	}
+}
+
+func negotiateLocale(acceptLanguage string) string {
+	preferences := []languagePreference{}
+
+	for _, part := range strings.Split(acceptLanguage, ",") {
+		fields := strings.Split(part, ";")
+		preference := languagePreference{strings.TrimSpace(fields[0]), 1}
+
+		for _, parameter := range fields[1:] {
+			parameter = strings.TrimSpace(parameter)
+
+			if strings.HasPrefix(parameter, "q=") {
+				if quality, parseError := strconv.ParseFloat(parameter[2:], 64); parseError == nil {
+					preference.quality = quality
+				}
+			}
+		}
+
+		if preference.tag != "" && preference.quality > 0 {
+			preferences = append(preferences, preference)
+		}
+	}
+
+	sort.SliceStable(preferences, func(i, j int) bool {
+		return preferences[i].quality > preferences[j].quality
+	})
+
+	for _, preference := range preferences {
+		if locale, found := resolveLocale(preference.tag); found {
+			return locale
+		}
+	}
+
+	return getLocale("")
+}
+
+func getRequestLocale(context echo.Context) string {
+	locale := negotiateLocale(context.Request().Header().Get("Accept-Language"))
+	context.Response().Header().Set("Content-Language", locale)
+	return locale
+}
+
+func translate(locale string, id string, arguments ...interface{}) string {
+	message, found := catalogs[getLocale(locale)].Messages[id]
+
+	if !found {
+		if message, found = catalogs["en"].Messages[id]; !found {
+			message = id
+		}
+	}
+
+	if len(arguments) == 0 {
+		return message
+	}
+	return fmt.Sprintf(message, arguments...)
+}
+
+func localizeSurveyText(locale string, text string) string {
+	if id, found := defaultSurveyTexts[text]; found {
+		return translate(locale, id)
+	}
+	return text
+}
+
+func localizeScaleLabel(locale string, label string) string {
+	if _, found := catalogs["en"].Messages["scale."+label]; found {
+		return translate(locale, "scale."+label)
+	}
+	return label
+}
+
+func formatDate(locale string, date time.Time) string {
+	catalog := catalogs[getLocale(locale)]
+	return fmt.Sprintf(catalog.ShortDate, catalog.Weekdays[date.Weekday()], date.Day(), catalog.Months[date.Month()-1], date.Year())
+}
+
+func formatLongDate(locale string, date time.Time) string {
+	catalog := catalogs[getLocale(locale)]
+	return fmt.Sprintf(catalog.LongDate, catalog.Weekdays[date.Weekday()], date.Day(), catalog.Months[date.Month()-1], date.Year())
+}
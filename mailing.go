@@ -1,67 +1,263 @@
 package main
 
 import (
+	"fmt"
 	"github.com/asdine/storm"
 	"github.com/ddliu/go-httpclient"
+	"github.com/nu7hatch/gouuid"
+	"html"
 	"log"
+	"net/mail"
 	"os"
+	"time"
 )
 
 type (
 	MailTask struct {
-		Email string
-		Key   string
+		SubscriberUuid string
+		Email          string
+		Key            string
+		Subject        string
+		Name           string
+		Locale         string
+		TimeZone       string
+		Channel        string
+		Kind           string
+		Organization   string
+		From           string
+		ReplyAddress   string
 	}
+
+	MailMessage struct {
+		To           string
+		Name         string
+		Locale       string
+		Subject      string
+		Html         string
+		Text         string
+		ReplyTo      string
+		MessageId    string
+		From         string
+		Organization string
+	}
+
+	Mailer interface {
+		Send(message MailMessage) error
+	}
+
+	mailgunMailer struct{}
+
+	MailDelivery struct {
+		Id             string    `json:"id" storm:"id"`
+		SubscriberUuid string    `json:"subscriber" storm:"index"`
+		Email          string    `json:"email"`
+		Key            string    `json:"key"`
+		Subject        string    `json:"subject"`
+		Kind           string    `json:"kind,omitempty"`
+		Status         string    `json:"status"`
+		Error          string    `json:"error,omitempty"`
+		SentAt         time.Time `json:"sent-at"`
+	}
+)
+
+const (
+	DeliverySent   = "sent"
+	DeliveryFailed = "failed"
+
+	MailOrganizationVariable = "organization"
+
+	MailSurvey   = "survey"
+	MailReminder = "reminder"
 )
 
 var BasicAuthHeader string = "Basic " + os.Getenv("MUT_BASIC_AUTH")
 var MailGunUrl string = os.Getenv("MUT_MAILGUN_URL")
+var MailFrom string = getEnvString("MUT_MAIL_FROM", "Mailgun Sandbox <postmaster@sandbox4ebeef9e81ca4130885ef51fa4b9729f.mailgun.org>")
+
+func (message MailMessage) Recipient() string {
+	if message.Name == "" {
+		return message.To
+	}
+	return (&mail.Address{Name: message.Name, Address: message.To}).String()
+}
+
+func (mailgunMailer) Send(message MailMessage) error {
+	parameters := map[string]string{
+		"from":    MailFrom,
+		"to":      message.Recipient(),
+		"subject": message.Subject,
+	}
 
-func sendMail(email string, text string) {
-	response, responseError := httpclient.WithHeader("Authorization", BasicAuthHeader).Post(MailGunUrl,
-		map[string]string{
-			"from":    "Mailgun Sandbox <postmaster@sandbox4ebeef9e81ca4130885ef51fa4b9729f.mailgun.org>",
-			"to":      email,
-			"subject": "How is your mood today?",
-			"html":    text,
-		})
+	if message.From != "" {
+		parameters["from"] = message.From
+	}
+	if message.Organization != "" {
+		parameters["v:"+MailOrganizationVariable] = message.Organization
+	}
+
+	if message.Html != "" {
+		parameters["html"] = message.Html
+	}
+	if message.Text != "" {
+		parameters["text"] = message.Text
+	}
+	if message.Locale != "" {
+		parameters["h:Content-Language"] = message.Locale
+	}
+	if message.ReplyTo != "" {
+		parameters["h:Reply-To"] = message.ReplyTo
+	}
+	if message.MessageId != "" {
+		parameters["h:Message-Id"] = message.MessageId
+	}
+
+	response, responseError := httpclient.WithHeader("Authorization", BasicAuthHeader).Post(MailGunUrl, parameters)
 	if responseError != nil {
 		log.Printf("%s", responseError)
+		return responseError
 	}
 
 	defer response.Body.Close()
+
+	if response.StatusCode >= 300 {
+		return fmt.Errorf("mail provider answered with status %d", response.StatusCode)
+	}
+
+	return nil
+}
+
+func newMailTask(organization *Organization, subscriber Subscriber, key string, subject string, kind string) MailTask {
+	task := MailTask{
+		SubscriberUuid: subscriber.Uuid,
+		Email:          subscriber.Email,
+		Key:            key,
+		Subject:        subject,
+		Name:           subscriber.Name,
+		Locale:         subscriber.Locale,
+		TimeZone:       subscriber.TimeZone,
+		Channel:        subscriber.Channel,
+		Kind:           kind,
+		ReplyAddress:   ReplyAddress,
+	}
+
+	if organization != nil {
+		task.Organization = organization.Id
+		task.From = organization.MailFrom
+
+		if organization.ReplyAddress != "" {
+			task.ReplyAddress = organization.ReplyAddress
+		}
+	}
+
+	return task
 }
 
-func triggerMail(database *storm.DB) func() {
+func triggerMail(database *storm.DB, repositories Repositories) func() {
 	return func() {
 		log.Println("Triggered mail sending!")
-		subscriptions, triggerError := getAllSubscribers(database)
+		subscriptions, triggerError := getAllSubscribers(repositories.Subscribers)
 
 		if triggerError != nil {
 			log.Printf("%s", triggerError)
 		}
 
-		mailTasks, triggerError := saveFeedbackIdentifierAndCreateMailTasks(subscriptions, database)
+		mailTasks, triggerError := saveFeedbackIdentifierAndCreateMailTasks(subscriptions, database, repositories)
 
 		if triggerError != nil {
 			log.Printf("%s", triggerError)
 		}
 
-		sendMails(mailTasks)
+		sendMails(repositories, mailTasks)
 	}
 }
 
-func sendMails(tasks []MailTask) {
+func sendMails(repositories Repositories, tasks []MailTask) {
 	for _, task := range tasks {
-		sendMail(task.Email, getHtmlText(task.Key))
+		mailError := repositories.Mailer.Send(createSurveyMail(task, repositories.Clock.Now()))
+
+		if databaseError := saveMailDelivery(repositories.MailTasks, task, mailError, repositories.Clock.Now()); databaseError != nil {
+			log.Printf("%s", databaseError)
+		}
+	}
+}
+
+func saveMailDelivery(mailTasks MailTaskRepository, task MailTask, mailError error, sentAt time.Time) error {
+	id, _ := uuid.NewV4()
+	delivery := MailDelivery{
+		Id:             id.String(),
+		SubscriberUuid: task.SubscriberUuid,
+		Email:          task.Email,
+		Key:            task.Key,
+		Subject:        task.Subject,
+		Kind:           task.Kind,
+		Status:         DeliverySent,
+		SentAt:         sentAt,
+	}
+
+	if mailError != nil {
+		delivery.Status = DeliveryFailed
+		delivery.Error = mailError.Error()
+	}
+
+	return mailTasks.Save(&delivery)
+}
+
+func createSurveyMail(task MailTask, now time.Time) (message MailMessage) {
+	locale := getLocale(task.Locale)
+	message = MailMessage{To: task.Email, Name: task.Name, Locale: locale, Subject: localizeSurveyText(locale, task.Subject), From: task.From, Organization: task.Organization}
+	message.ReplyTo = getReplyAddress(task.ReplyAddress, task.Key)
+	message.MessageId = getMessageId(task.ReplyAddress, task.Key, task.Kind, now)
+	today := formatDate(locale, now.In(getLocation(task.TimeZone)))
+	greeting := getGreeting(task.Name, locale, "mail.greeting")
+
+	if task.Kind == MailReminder {
+		greeting = getGreeting(task.Name, locale, "reminder.greeting")
+
+		if task.Subject == "" {
+			message.Subject = translate(locale, "reminder.subject")
+		}
+	}
+
+	preferences := getPreferenceUrl(scopeKey(task.Organization, task.SubscriberUuid))
+
+	if task.Channel == ChannelTextMail {
+		message.Text = getPlainText(task.Key, greeting, today, locale, preferences)
+	} else {
+		message.Html = getHtmlText(task.Key, greeting, today, locale, preferences)
+	}
+
+	return message
+}
+
+func getGreeting(name string, locale string, id string) string {
+	if name == "" {
+		return translate(locale, id)
 	}
+	return translate(locale, id+".named", name)
 }
 
-func getHtmlText(key string) string {
-	return `<html>
+func getHtmlText(key string, greeting string, today string, locale string, preferences string) string {
+	if preferences != "" {
+		preferences = `<p><a href="` + html.EscapeString(preferences) + `">` + html.EscapeString(translate(locale, "mail.preferences")) + `</a></p>`
+	}
+
+	return `<html lang="` + locale + `">
+	<head><meta charset="utf-8"></head>
 	<body>
-	<h1>Select your mood</h1>
-	<a href="http://mut-musca.rhcloud.com/moods/` + key + `">Take me to the mood selection!</a>
+	<h1>` + html.EscapeString(greeting) + `</h1>
+	<p>` + html.EscapeString(today) + `</p>
+	<a href="` + BaseUrl + `/moods/` + key + `">` + html.EscapeString(translate(locale, "mail.link")) + `</a>
+	` + preferences + `
 	</body>
 	</html>`
 }
+
+func getPlainText(key string, greeting string, today string, locale string, preferences string) string {
+	text := greeting + "\n\n" + today + "\n\n" + translate(locale, "mail.link") + " " + BaseUrl + "/moods/" + key + "\n"
+
+	if preferences != "" {
+		text += "\n" + translate(locale, "mail.preferences") + ": " + preferences + "\n"
+	}
+
+	return text
+}
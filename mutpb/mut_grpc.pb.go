@@ -0,0 +1,553 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.5.1
+// - protoc             v4.25.1
+// source: mut.proto
+
+package mutpb
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.64.0 or later.
+const _ = grpc.SupportPackageIsVersion9
+
+const (
+	Mut_ListSubscribers_FullMethodName  = "/mut.v1.Mut/ListSubscribers"
+	Mut_GetSubscriber_FullMethodName    = "/mut.v1.Mut/GetSubscriber"
+	Mut_CreateSubscriber_FullMethodName = "/mut.v1.Mut/CreateSubscriber"
+	Mut_DeleteSubscriber_FullMethodName = "/mut.v1.Mut/DeleteSubscriber"
+	Mut_ListSurveys_FullMethodName      = "/mut.v1.Mut/ListSurveys"
+	Mut_GetSurvey_FullMethodName        = "/mut.v1.Mut/GetSurvey"
+	Mut_ActivateSurvey_FullMethodName   = "/mut.v1.Mut/ActivateSurvey"
+	Mut_GetSurveyResults_FullMethodName = "/mut.v1.Mut/GetSurveyResults"
+	Mut_GetBallot_FullMethodName        = "/mut.v1.Mut/GetBallot"
+	Mut_Vote_FullMethodName             = "/mut.v1.Mut/Vote"
+	Mut_ListMoods_FullMethodName        = "/mut.v1.Mut/ListMoods"
+	Mut_WatchVotes_FullMethodName       = "/mut.v1.Mut/WatchVotes"
+)
+
+// MutClient is the client API for Mut service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+//
+// Mut mirrors the REST routes for subscribers, surveys, voting and statistics.
+// Methods that are admin-only over REST require the admin token as
+// "authorization: Bearer <token>" metadata here as well.
+type MutClient interface {
+	ListSubscribers(ctx context.Context, in *ListSubscribersRequest, opts ...grpc.CallOption) (*ListSubscribersResponse, error)
+	GetSubscriber(ctx context.Context, in *GetSubscriberRequest, opts ...grpc.CallOption) (*Subscriber, error)
+	CreateSubscriber(ctx context.Context, in *CreateSubscriberRequest, opts ...grpc.CallOption) (*Subscriber, error)
+	DeleteSubscriber(ctx context.Context, in *DeleteSubscriberRequest, opts ...grpc.CallOption) (*DeleteSubscriberResponse, error)
+	ListSurveys(ctx context.Context, in *ListSurveysRequest, opts ...grpc.CallOption) (*ListSurveysResponse, error)
+	GetSurvey(ctx context.Context, in *GetSurveyRequest, opts ...grpc.CallOption) (*Survey, error)
+	ActivateSurvey(ctx context.Context, in *ActivateSurveyRequest, opts ...grpc.CallOption) (*ActivateSurveyResponse, error)
+	GetSurveyResults(ctx context.Context, in *GetSurveyResultsRequest, opts ...grpc.CallOption) (*GetSurveyResultsResponse, error)
+	GetBallot(ctx context.Context, in *GetBallotRequest, opts ...grpc.CallOption) (*Ballot, error)
+	Vote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteResponse, error)
+	ListMoods(ctx context.Context, in *ListMoodsRequest, opts ...grpc.CallOption) (*ListMoodsResponse, error)
+	// WatchVotes streams every recorded vote until the client cancels.
+	WatchVotes(ctx context.Context, in *WatchVotesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[VoteEvent], error)
+}
+
+type mutClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewMutClient(cc grpc.ClientConnInterface) MutClient {
+	return &mutClient{cc}
+}
+
+func (c *mutClient) ListSubscribers(ctx context.Context, in *ListSubscribersRequest, opts ...grpc.CallOption) (*ListSubscribersResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSubscribersResponse)
+	err := c.cc.Invoke(ctx, Mut_ListSubscribers_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) GetSubscriber(ctx context.Context, in *GetSubscriberRequest, opts ...grpc.CallOption) (*Subscriber, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Subscriber)
+	err := c.cc.Invoke(ctx, Mut_GetSubscriber_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) CreateSubscriber(ctx context.Context, in *CreateSubscriberRequest, opts ...grpc.CallOption) (*Subscriber, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Subscriber)
+	err := c.cc.Invoke(ctx, Mut_CreateSubscriber_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) DeleteSubscriber(ctx context.Context, in *DeleteSubscriberRequest, opts ...grpc.CallOption) (*DeleteSubscriberResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(DeleteSubscriberResponse)
+	err := c.cc.Invoke(ctx, Mut_DeleteSubscriber_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) ListSurveys(ctx context.Context, in *ListSurveysRequest, opts ...grpc.CallOption) (*ListSurveysResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListSurveysResponse)
+	err := c.cc.Invoke(ctx, Mut_ListSurveys_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) GetSurvey(ctx context.Context, in *GetSurveyRequest, opts ...grpc.CallOption) (*Survey, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Survey)
+	err := c.cc.Invoke(ctx, Mut_GetSurvey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) ActivateSurvey(ctx context.Context, in *ActivateSurveyRequest, opts ...grpc.CallOption) (*ActivateSurveyResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ActivateSurveyResponse)
+	err := c.cc.Invoke(ctx, Mut_ActivateSurvey_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) GetSurveyResults(ctx context.Context, in *GetSurveyResultsRequest, opts ...grpc.CallOption) (*GetSurveyResultsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(GetSurveyResultsResponse)
+	err := c.cc.Invoke(ctx, Mut_GetSurveyResults_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) GetBallot(ctx context.Context, in *GetBallotRequest, opts ...grpc.CallOption) (*Ballot, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(Ballot)
+	err := c.cc.Invoke(ctx, Mut_GetBallot_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) Vote(ctx context.Context, in *VoteRequest, opts ...grpc.CallOption) (*VoteResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(VoteResponse)
+	err := c.cc.Invoke(ctx, Mut_Vote_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) ListMoods(ctx context.Context, in *ListMoodsRequest, opts ...grpc.CallOption) (*ListMoodsResponse, error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	out := new(ListMoodsResponse)
+	err := c.cc.Invoke(ctx, Mut_ListMoods_FullMethodName, in, out, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *mutClient) WatchVotes(ctx context.Context, in *WatchVotesRequest, opts ...grpc.CallOption) (grpc.ServerStreamingClient[VoteEvent], error) {
+	cOpts := append([]grpc.CallOption{grpc.StaticMethod()}, opts...)
+	stream, err := c.cc.NewStream(ctx, &Mut_ServiceDesc.Streams[0], Mut_WatchVotes_FullMethodName, cOpts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &grpc.GenericClientStream[WatchVotesRequest, VoteEvent]{ClientStream: stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Mut_WatchVotesClient = grpc.ServerStreamingClient[VoteEvent]
+
+// MutServer is the server API for Mut service.
+// All implementations must embed UnimplementedMutServer
+// for forward compatibility.
+//
+// Mut mirrors the REST routes for subscribers, surveys, voting and statistics.
+// Methods that are admin-only over REST require the admin token as
+// "authorization: Bearer <token>" metadata here as well.
+type MutServer interface {
+	ListSubscribers(context.Context, *ListSubscribersRequest) (*ListSubscribersResponse, error)
+	GetSubscriber(context.Context, *GetSubscriberRequest) (*Subscriber, error)
+	CreateSubscriber(context.Context, *CreateSubscriberRequest) (*Subscriber, error)
+	DeleteSubscriber(context.Context, *DeleteSubscriberRequest) (*DeleteSubscriberResponse, error)
+	ListSurveys(context.Context, *ListSurveysRequest) (*ListSurveysResponse, error)
+	GetSurvey(context.Context, *GetSurveyRequest) (*Survey, error)
+	ActivateSurvey(context.Context, *ActivateSurveyRequest) (*ActivateSurveyResponse, error)
+	GetSurveyResults(context.Context, *GetSurveyResultsRequest) (*GetSurveyResultsResponse, error)
+	GetBallot(context.Context, *GetBallotRequest) (*Ballot, error)
+	Vote(context.Context, *VoteRequest) (*VoteResponse, error)
+	ListMoods(context.Context, *ListMoodsRequest) (*ListMoodsResponse, error)
+	// WatchVotes streams every recorded vote until the client cancels.
+	WatchVotes(*WatchVotesRequest, grpc.ServerStreamingServer[VoteEvent]) error
+	mustEmbedUnimplementedMutServer()
+}
+
+// UnimplementedMutServer must be embedded to have
+// forward compatible implementations.
+//
+// NOTE: this should be embedded by value instead of pointer to avoid a nil
+// pointer dereference when methods are called.
+type UnimplementedMutServer struct{}
+
+func (UnimplementedMutServer) ListSubscribers(context.Context, *ListSubscribersRequest) (*ListSubscribersResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSubscribers not implemented")
+}
+func (UnimplementedMutServer) GetSubscriber(context.Context, *GetSubscriberRequest) (*Subscriber, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSubscriber not implemented")
+}
+func (UnimplementedMutServer) CreateSubscriber(context.Context, *CreateSubscriberRequest) (*Subscriber, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method CreateSubscriber not implemented")
+}
+func (UnimplementedMutServer) DeleteSubscriber(context.Context, *DeleteSubscriberRequest) (*DeleteSubscriberResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method DeleteSubscriber not implemented")
+}
+func (UnimplementedMutServer) ListSurveys(context.Context, *ListSurveysRequest) (*ListSurveysResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListSurveys not implemented")
+}
+func (UnimplementedMutServer) GetSurvey(context.Context, *GetSurveyRequest) (*Survey, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSurvey not implemented")
+}
+func (UnimplementedMutServer) ActivateSurvey(context.Context, *ActivateSurveyRequest) (*ActivateSurveyResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ActivateSurvey not implemented")
+}
+func (UnimplementedMutServer) GetSurveyResults(context.Context, *GetSurveyResultsRequest) (*GetSurveyResultsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetSurveyResults not implemented")
+}
+func (UnimplementedMutServer) GetBallot(context.Context, *GetBallotRequest) (*Ballot, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetBallot not implemented")
+}
+func (UnimplementedMutServer) Vote(context.Context, *VoteRequest) (*VoteResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Vote not implemented")
+}
+func (UnimplementedMutServer) ListMoods(context.Context, *ListMoodsRequest) (*ListMoodsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListMoods not implemented")
+}
+func (UnimplementedMutServer) WatchVotes(*WatchVotesRequest, grpc.ServerStreamingServer[VoteEvent]) error {
+	return status.Errorf(codes.Unimplemented, "method WatchVotes not implemented")
+}
+func (UnimplementedMutServer) mustEmbedUnimplementedMutServer() {}
+func (UnimplementedMutServer) testEmbeddedByValue()             {}
+
+// UnsafeMutServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to MutServer will
+// result in compilation errors.
+type UnsafeMutServer interface {
+	mustEmbedUnimplementedMutServer()
+}
+
+func RegisterMutServer(s grpc.ServiceRegistrar, srv MutServer) {
+	// If the following call pancis, it indicates UnimplementedMutServer was
+	// embedded by pointer and is nil.  This will cause panics if an
+	// unimplemented method is ever invoked, so we test this at initialization
+	// time to prevent it from happening at runtime later due to I/O.
+	if t, ok := srv.(interface{ testEmbeddedByValue() }); ok {
+		t.testEmbeddedByValue()
+	}
+	s.RegisterService(&Mut_ServiceDesc, srv)
+}
+
+func _Mut_ListSubscribers_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSubscribersRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).ListSubscribers(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_ListSubscribers_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).ListSubscribers(ctx, req.(*ListSubscribersRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_GetSubscriber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSubscriberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).GetSubscriber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_GetSubscriber_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).GetSubscriber(ctx, req.(*GetSubscriberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_CreateSubscriber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(CreateSubscriberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).CreateSubscriber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_CreateSubscriber_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).CreateSubscriber(ctx, req.(*CreateSubscriberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_DeleteSubscriber_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(DeleteSubscriberRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).DeleteSubscriber(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_DeleteSubscriber_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).DeleteSubscriber(ctx, req.(*DeleteSubscriberRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_ListSurveys_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListSurveysRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).ListSurveys(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_ListSurveys_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).ListSurveys(ctx, req.(*ListSurveysRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_GetSurvey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSurveyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).GetSurvey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_GetSurvey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).GetSurvey(ctx, req.(*GetSurveyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_ActivateSurvey_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ActivateSurveyRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).ActivateSurvey(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_ActivateSurvey_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).ActivateSurvey(ctx, req.(*ActivateSurveyRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_GetSurveyResults_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetSurveyResultsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).GetSurveyResults(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_GetSurveyResults_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).GetSurveyResults(ctx, req.(*GetSurveyResultsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_GetBallot_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetBallotRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).GetBallot(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_GetBallot_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).GetBallot(ctx, req.(*GetBallotRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_Vote_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(VoteRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).Vote(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_Vote_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).Vote(ctx, req.(*VoteRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_ListMoods_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListMoodsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(MutServer).ListMoods(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Mut_ListMoods_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(MutServer).ListMoods(ctx, req.(*ListMoodsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Mut_WatchVotes_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchVotesRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(MutServer).WatchVotes(m, &grpc.GenericServerStream[WatchVotesRequest, VoteEvent]{ServerStream: stream})
+}
+
+// This type alias is provided for backwards compatibility with existing code that references the prior non-generic stream type by name.
+type Mut_WatchVotesServer = grpc.ServerStreamingServer[VoteEvent]
+
+// Mut_ServiceDesc is the grpc.ServiceDesc for Mut service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Mut_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mut.v1.Mut",
+	HandlerType: (*MutServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "ListSubscribers",
+			Handler:    _Mut_ListSubscribers_Handler,
+		},
+		{
+			MethodName: "GetSubscriber",
+			Handler:    _Mut_GetSubscriber_Handler,
+		},
+		{
+			MethodName: "CreateSubscriber",
+			Handler:    _Mut_CreateSubscriber_Handler,
+		},
+		{
+			MethodName: "DeleteSubscriber",
+			Handler:    _Mut_DeleteSubscriber_Handler,
+		},
+		{
+			MethodName: "ListSurveys",
+			Handler:    _Mut_ListSurveys_Handler,
+		},
+		{
+			MethodName: "GetSurvey",
+			Handler:    _Mut_GetSurvey_Handler,
+		},
+		{
+			MethodName: "ActivateSurvey",
+			Handler:    _Mut_ActivateSurvey_Handler,
+		},
+		{
+			MethodName: "GetSurveyResults",
+			Handler:    _Mut_GetSurveyResults_Handler,
+		},
+		{
+			MethodName: "GetBallot",
+			Handler:    _Mut_GetBallot_Handler,
+		},
+		{
+			MethodName: "Vote",
+			Handler:    _Mut_Vote_Handler,
+		},
+		{
+			MethodName: "ListMoods",
+			Handler:    _Mut_ListMoods_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchVotes",
+			Handler:       _Mut_WatchVotes_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "mut.proto",
+}
@@ -1,14 +1,16 @@
 package main
 
 import (
+	"fmt"
 	"github.com/asdine/storm"
 	"github.com/labstack/echo"
-	"github.com/labstack/echo/engine/fasthttp"
 	"github.com/labstack/echo/middleware"
-	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"os"
+	"strconv"
+	"strings"
 )
 
 type (
@@ -21,21 +23,70 @@ type (
 	}
 
 	Subscription struct {
-		Email string `json:"email"`
+		Email       string            `json:"email"`
+		Team        string            `json:"team"`
+		Name        string            `json:"name,omitempty"`
+		Locale      string            `json:"locale,omitempty"`
+		TimeZone    string            `json:"time-zone,omitempty"`
+		Channel     string            `json:"channel,omitempty"`
+		Attributes  map[string]string `json:"attributes,omitempty"`
+		NoReminders bool              `json:"no-reminders,omitempty"`
+		Frequency   string            `json:"frequency,omitempty"`
+		Weekdays    []string          `json:"weekdays,omitempty"`
 	}
 )
 
 func main() {
-	database := createDatabase()
+	if len(os.Args) == 3 && os.Args[1] == "restore" {
+		if restoreError := restoreSnapshot(os.Args[2], getDatabasePath(), systemClock{}); restoreError != nil {
+			log.Fatal(restoreError)
+		}
+		return
+	}
+
+	if len(os.Args) == 4 && os.Args[1] == "restore" {
+		if !organizationIdPattern.MatchString(os.Args[3]) {
+			log.Fatalf("Organization id '%s' is invalid!", os.Args[3])
+		}
+		if restoreError := restoreSnapshot(os.Args[2], getOrganizationDatabasePath(os.Args[3]), systemClock{}); restoreError != nil {
+			log.Fatal(restoreError)
+		}
+		return
+	}
+
+	database := createDatabase(systemClock{})
 	defer database.Close()
 
-	createCronJob(database, triggerMail(database))
+	if databaseError := loadNoiseSecret(database); databaseError != nil {
+		log.Fatal(databaseError)
+	}
+
+	repositories := newStormRepositories(database)
+
+	scheduler := newCronScheduler()
+
+	if scheduleError := scheduleJobs(scheduler, database, repositories); scheduleError != nil {
+		log.Fatal(scheduleError)
+	}
+
+	scheduler.Start()
+
+	tenants, databaseError := openTenants(database, repositories)
+
+	if databaseError != nil {
+		log.Fatal(databaseError)
+	}
+
+	defer tenants.Close()
+
+	server := initServer(database, repositories)
+	mountOrganizations(server, tenants)
 
-	server := initServer(database)
+	go serveGrpc(GrpcBind, database, repositories, tenants)
 
 	bind := getBind()
 	log.Println("Starting server on bind " + bind + ".")
-	server.Run(fasthttp.New(bind))
+	server.Run(createEngine(bind))
 }
 
 func getBind() string {
@@ -46,98 +97,203 @@ func getBind() string {
 	}
 }
 
-func initServer(database *storm.DB) (server *echo.Echo) {
+func getEnvString(name string, fallback string) string {
+	if value := os.Getenv(name); value != "" {
+		return value
+	} else {
+		return fallback
+	}
+}
+
+func getEnvInt(name string, fallback int) int {
+	if value, parseError := strconv.Atoi(os.Getenv(name)); parseError == nil {
+		return value
+	} else {
+		return fallback
+	}
+}
+
+func getEnvFloat(name string, fallback float64) float64 {
+	if value, parseError := strconv.ParseFloat(os.Getenv(name), 64); parseError == nil {
+		return value
+	} else {
+		return fallback
+	}
+}
+
+func initServer(database *storm.DB, repositories Repositories) (server *echo.Echo) {
 	server = echo.New()
 
+	limiter := newRateLimiter(RateLimitPerMinute, RateLimitBurst, repositories.Clock)
+	banList := newBanList(BanThreshold, BanWindow, BanDuration, repositories.Clock)
+
 	server.Use(middleware.Logger())
-	server.Get("/subscribers", getSubscribers(database))
-	server.Get("/subscribers/:uuid", getSubscribersByUuid(database))
-	server.Post("/subscribers", postSubscriber(database))
-	server.Get("/moods", getDailyMoods(database))
-	server.Get("/moods/:key", getDailyMoodsForm())
-	server.Post("/moods/:key", postDailyMoods(database))
+	server.Use(securityHeaders())
+	server.Get("/openapi.json", getOpenApiDocument())
+	server.Get("/subscribers", getSubscribers(repositories), adminAuth(database))
+	server.Get("/subscribers/:uuid", getSubscribersByUuid(repositories), adminAuth(database))
+	server.Post("/subscribers", postSubscriber(database, repositories), rateLimit(limiter, "POST /subscribers"))
+	server.Get("/subscribers/:uuid/data", getSubscriberData(database, repositories), adminAuth(database))
+	server.Patch("/subscribers/:uuid", patchSubscriber(repositories), adminAuth(database))
+	server.Delete("/subscribers/:uuid", deleteSubscriber(database, repositories), adminAuth(database))
+	server.Get("/subscribers/:uuid/delivery-health", getSubscriberDeliveryHealth(database, repositories), adminAuth(database))
+	server.Post("/mail/events/mailgun", postMailgunEvent(database, repositories), rateLimit(limiter, "POST /mail/events/mailgun"))
+	server.Post("/mail/events/dsn", postDsn(database, repositories), adminAuth(database))
+	server.Post("/mail/replies", postMailgunReply(database, repositories), rateLimit(limiter, "POST /mail/replies"))
+	server.Post("/mail/replies/raw", postRawReply(database, repositories), adminAuth(database))
+	server.Post("/subscribers/:uuid/pause", postPause(repositories), adminAuth(database))
+	server.Delete("/subscribers/:uuid/pause", deletePause(repositories), adminAuth(database))
+	server.Get("/preferences/:token", getPreferencesForm(repositories), rateLimit(limiter, "GET /preferences/:token"), formSecurityPolicy())
+	server.Post("/preferences/:token", postPreferences(repositories), rateLimit(limiter, "POST /preferences/:token"))
+	server.Get("/admin/audit", getAuditTrail(database), adminAuth(database))
+	server.Post("/admin/purge", postPurge(database, repositories), adminAuth(database))
+	server.Get("/admin/backup", getBackup(database, repositories), adminAuth(database))
+	server.Get("/admin/tokens", getOrganizationTokens(database), adminAuth(database))
+	server.Post("/admin/tokens", postOrganizationToken(database, repositories), adminAuth(database))
+	server.Delete("/admin/tokens/:id", deleteOrganizationToken(database), adminAuth(database))
+	server.Get("/moods", getDailyMoods(repositories))
+	server.Get("/moods/stream", getMoodStream(database, repositories), rateLimit(limiter, "GET /moods/stream"))
+	server.Get("/moods/:key", getDailyMoodsForm(database, repositories), rateLimit(limiter, "GET /moods/:key"), banOnNotFound(banList), formSecurityPolicy())
+	server.Post("/moods/:key", postDailyMoods(database, repositories), rateLimit(limiter, "POST /moods/:key"), banOnNotFound(banList))
+	server.Get("/surveys", getSurveys(database))
+	server.Get("/surveys/:id", getSurveyById(database))
+	server.Get("/surveys/:id/results", getSurveyResults(database))
+	server.Post("/surveys", postSurvey(database, repositories), adminAuth(database))
+	server.Put("/surveys/:id", putSurvey(database, repositories), adminAuth(database))
+	server.Post("/surveys/:id/activate", postActiveSurvey(database), adminAuth(database))
+	server.Get("/admin/responses", getResponses(database), adminAuth(database))
+	server.Delete("/admin/responses/:id", deleteResponse(database, repositories), adminAuth(database))
+	server.Post("/admin/rollups/rebuild", postRebuildRollups(database, repositories), adminAuth(database))
+	server.Get("/alerts", getAlerts(database), adminAuth(database))
+	server.Get("/alerts/rules", getAlertRules(database), adminAuth(database))
+	server.Post("/alerts/rules", postAlertRule(database), adminAuth(database))
+	server.Delete("/alerts/rules/:id", deleteAlertRule(database), adminAuth(database))
+	server.Get("/webhooks", getWebhooks(database), adminAuth(database))
+	server.Post("/webhooks", postWebhook(database, repositories), adminAuth(database))
+	server.Delete("/webhooks/:id", deleteWebhook(database), adminAuth(database))
+	server.Get("/webhooks/:id/deliveries", getWebhookDeliveries(database), adminAuth(database))
+	server.Post("/webhooks/:id/deliveries/:delivery/redeliver", postRedelivery(database, repositories), adminAuth(database))
 
 	return server
 }
 
-func getDailyMoods(database *storm.DB) echo.HandlerFunc {
+func getDailyMoods(repositories Repositories) echo.HandlerFunc {
 	return (func(context echo.Context) error {
-		dailyMoods, databaseError := getAllDailyMoods(database)
+		page, parseError := parsePage(context, MoodPageMonths, MoodPageMaximumMonths)
+
+		if parseError != nil {
+			return respondWithPaginationError(context, parseError)
+		}
+
+		filter, parseError := parseMoodFilter(context)
+
+		if parseError != nil {
+			return respondWithPaginationError(context, parseError)
+		}
+
+		published, next, databaseError := getPublishedMoodsPage(repositories.Moods, filter, page)
+
+		if databaseError != nil {
+			return respondWithPaginationError(context, databaseError)
+		}
+
+		total, databaseError := repositories.Moods.Count(filter.bounds())
 
 		if databaseError != nil {
 			return databaseError
 		} else {
-			return context.JSON(http.StatusOK, dailyMoods)
+			setPaginationHeaders(context, next, total)
+			return context.JSON(http.StatusOK, published)
 		}
 
 		return nil
 	})
 }
 
-func getDailyMoodsForm() echo.HandlerFunc {
+func getDailyMoodsForm(database *storm.DB, repositories Repositories) echo.HandlerFunc {
 	return (func(context echo.Context) error {
 		key := context.Param("key")
+		locale := getRequestLocale(context)
+
+		if feedbackIdentifier := getFeedbackIdentifier(repositories.FeedbackKeys, key); feedbackIdentifier != nil {
+			dailyMoods, databaseError := getDailyMoodsByDate(repositories.Moods, feedbackIdentifier.DateString)
+
+			if databaseError != nil {
+				return databaseError
+			}
+
+			survey, databaseError := getSurveyOfDay(database, dailyMoods)
+
+			if databaseError != nil {
+				return databaseError
+			} else {
+				return context.HTML(http.StatusOK, getSurveyFormHtmlText(key, survey, locale))
+			}
+		} else {
+			return context.String(http.StatusNotFound, translate(locale, "form.not-found", key))
+		}
+	})
+}
 
-		htmlContent := `<html>
+func getFormHtmlText(key string, scale Scale, locale string) string {
+	forms := make([]string, 0, len(scale.Points))
+
+	for _, point := range scale.Points {
+		forms = append(forms, `<form method="POST" action="`+html.EscapeString(BaseUrl+"/moods/"+key)+`">
+	<input type="hidden" name="mood" value="`+strconv.Itoa(point.Value)+`">
+	<input type="submit" value="`+html.EscapeString(point.Emoji+" "+localizeScaleLabel(locale, point.Label))+`">
+	</form>`)
+	}
+
+	return `<html lang="` + locale + `">
+	<head><meta charset="utf-8"></head>
 	<body>
-	<h1>Select your mood</h1>
-	<form method="POST" action="http://mut-musca.rhcloud.com/moods/` + key + `">
-	<input type="hidden" name="mood" value="0">
-	<input type="submit" value="Very unhappy">
-	</form>
-	<br/>
-	<form method="POST" action="http://mut-musca.rhcloud.com/moods/` + key + `">
-	<input type="hidden" name="mood" value="1">
-	<input type="submit" value="Unhappy">
-	</form>
-	<br/>
-	<form method="POST" action="http://mut-musca.rhcloud.com/moods/` + key + `">
-	<input type="hidden" name="mood" value="2">
-	<input type="submit" value="Neutral">
-	</form>
-	<br/>
-	<form method="POST" action="http://mut-musca.rhcloud.com/moods/` + key + `">
-	<input type="hidden" name="mood" value="3">
-	<input type="submit" value="Happy">
-	</form>
-	<br/>
-	<form method="POST" action="http://mut-musca.rhcloud.com/moods/` + key + `">
-	<input type="hidden" name="mood" value="4">
-	<input type="submit" value="Very happy">
-	</form>
+	<h1>` + html.EscapeString(translate(locale, "survey.question")) + `</h1>
+	` + strings.Join(forms, "\n\t<br/>\n\t") + `
 	</body>
 	</html>`
-		return context.HTML(http.StatusOK, htmlContent)
-
-	})
 }
 
-func postDailyMoods(database *storm.DB) echo.HandlerFunc {
+func postDailyMoods(database *storm.DB, repositories Repositories) echo.HandlerFunc {
 	return (func(context echo.Context) error {
 		key := context.Param("key")
-		mood := context.FormValue("mood")
+		locale := getRequestLocale(context)
+		answers := Answers(context.Request().FormParams())
 
-		if feedbackIdentifier := getFeedbackIdentifier(database, key); feedbackIdentifier != nil {
-			if databaseError := updateDailyMoods(database, feedbackIdentifier.DateString, mood); databaseError != nil {
+		if feedbackIdentifier := getFeedbackIdentifier(repositories.FeedbackKeys, key); feedbackIdentifier != nil {
+			if databaseError := recordSurveyAnswers(database, repositories, feedbackIdentifier, answers, ChannelWeb); databaseError != nil {
+				if answerError, invalid := databaseError.(*AnswerError); invalid {
+					return context.String(http.StatusBadRequest, answerError.Message)
+				} else if databaseError == ErrNotFound {
+					return context.String(http.StatusNotFound, translate(locale, "form.not-found", key))
+				}
 				return databaseError
 			} else {
-				return context.String(http.StatusCreated, "Thank you!")
+				return context.String(http.StatusCreated, translate(locale, "form.thanks"))
 			}
 		} else {
-			return context.String(http.StatusNotFound, "Mood with key '"+key+"' not found!")
+			return context.String(http.StatusNotFound, translate(locale, "form.not-found", key))
 		}
 
 		return nil
 	})
 }
 
-func getSubscribers(database *storm.DB) echo.HandlerFunc {
+func getSubscribers(repositories Repositories) echo.HandlerFunc {
 	return (func(context echo.Context) error {
-		subscribers, databaseError := getAllSubscribers(database)
+		page, parseError := parsePage(context, SubscriberPageLimit, SubscriberPageMaximum)
+
+		if parseError != nil {
+			return respondWithPaginationError(context, parseError)
+		}
+
+		filter := SubscriberFilter{context.QueryParam("email-prefix"), context.QueryParam("status")}
+		subscribers, next, total, databaseError := getSubscribersPage(repositories.Subscribers, filter, page)
 
 		if databaseError != nil {
 			return databaseError
 		} else {
+			setPaginationHeaders(context, next, total)
 			return context.JSON(http.StatusOK, subscribers)
 		}
 
@@ -145,15 +301,15 @@ func getSubscribers(database *storm.DB) echo.HandlerFunc {
 	})
 }
 
-func getSubscribersByUuid(database *storm.DB) echo.HandlerFunc {
+func getSubscribersByUuid(repositories Repositories) echo.HandlerFunc {
 	return (func(context echo.Context) error {
 		uuid := context.Param("uuid")
-		subscriber, databaseError := getSubscriberByUuid(database, uuid)
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
 
-		if databaseError != nil {
+		if databaseError != nil && databaseError != ErrNotFound {
 			return databaseError
 		} else {
-			if subscriber != nil {
+			if databaseError == nil {
 				return context.JSON(http.StatusOK, subscriber)
 			} else {
 				return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
@@ -164,18 +320,23 @@ func getSubscribersByUuid(database *storm.DB) echo.HandlerFunc {
 	})
 }
 
-func postSubscriber(db *storm.DB) echo.HandlerFunc {
+func postSubscriber(database *storm.DB, repositories Repositories) echo.HandlerFunc {
 	return (func(context echo.Context) error {
 		subscription := new(Subscription)
 
 		if jsonError := context.Bind(subscription); jsonError != nil {
 			return jsonError
 		} else {
-			subscriber, databaseError := saveSubscriber(db, subscription)
+			subscriber, databaseError := saveSubscriber(repositories, subscription)
 
-			if databaseError != nil {
+			if profileError, invalid := databaseError.(*ProfileError); invalid {
+				return context.String(http.StatusBadRequest, profileError.Message)
+			} else if databaseError == ErrDuplicate {
+				return context.String(http.StatusConflict, "User with email '"+subscription.Email+"' already exists!")
+			} else if databaseError != nil {
 				return databaseError
 			} else {
+				publishEvent(database, repositories.Clock, EventSubscriberCreated, SubscriberEvent{subscriber.Uuid, subscriber.Team})
 				return context.JSON(http.StatusCreated, subscriber)
 			}
 		}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"fmt"
+	"github.com/labstack/echo"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+)
+
+type (
+	apiOperation struct {
+		Method       string
+		Path         string
+		Summary      string
+		Admin        bool
+		Query        []string
+		Request      interface{}
+		RequestType  string
+		Status       int
+		Response     interface{}
+		ResponseType string
+		Errors       []int
+	}
+
+	schemaRegistry struct {
+		schemas map[string]interface{}
+	}
+)
+
+const (
+	contentJson        = "application/json"
+	contentForm        = "application/x-www-form-urlencoded"
+	contentMergePatch  = "application/merge-patch+json"
+	contentText        = "text/plain"
+	contentHtml        = "text/html"
+	contentData        = "application/octet-stream"
+	contentEventStream = "text/event-stream"
+)
+
+var apiOperations = []apiOperation{
+	{Method: echo.GET, Path: "/openapi.json", Summary: "This OpenAPI document", Status: http.StatusOK, Response: map[string]interface{}{}},
+	{Method: echo.GET, Path: "/subscribers", Summary: "List subscribers ordered by email, one page at a time", Admin: true, Query: []string{"limit", "cursor", "order", "email-prefix", "status"}, Status: http.StatusOK, Response: []Subscriber{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.GET, Path: "/subscribers/:uuid", Summary: "Get a subscriber", Admin: true, Status: http.StatusOK, Response: Subscriber{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.POST, Path: "/subscribers", Summary: "Subscribe to the daily survey", Request: Subscription{}, Status: http.StatusCreated, Response: Subscriber{}, Errors: []int{http.StatusBadRequest, http.StatusConflict, http.StatusTooManyRequests}},
+	{Method: echo.GET, Path: "/subscribers/:uuid/data", Summary: "Export all data stored about a subscriber", Admin: true, Status: http.StatusOK, Response: SubscriberData{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.PATCH, Path: "/subscribers/:uuid", Summary: "Update a subscriber profile with a JSON merge patch", Admin: true, Request: map[string]interface{}{}, RequestType: contentMergePatch, Status: http.StatusOK, Response: Subscriber{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusConflict}},
+	{Method: echo.POST, Path: "/subscribers/:uuid/pause", Summary: "Pause the surveys of a subscriber from a day, until a day or indefinitely", Admin: true, Request: PauseRequest{}, Status: http.StatusOK, Response: Subscriber{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: echo.DELETE, Path: "/subscribers/:uuid/pause", Summary: "Resume the surveys of a subscriber", Admin: true, Status: http.StatusOK, Response: Subscriber{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/preferences/:token", Summary: "Render the survey preferences linked from every mail", Status: http.StatusOK, Response: "", ResponseType: contentHtml, Errors: []int{http.StatusNotFound, http.StatusTooManyRequests}},
+	{Method: echo.POST, Path: "/preferences/:token", Summary: "Pause or resume surveys from the preferences form", Request: PreferenceChoice{}, RequestType: contentForm, Status: http.StatusOK, Response: "", ResponseType: contentHtml, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusTooManyRequests}},
+	{Method: echo.GET, Path: "/subscribers/:uuid/delivery-health", Summary: "Show bounces, complaints and mail deliveries of a subscriber", Admin: true, Status: http.StatusOK, Response: DeliveryHealthReport{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.POST, Path: "/mail/events/mailgun", Summary: "Receive a signed Mailgun bounce, complaint, unsubscribe or delivery event", Request: map[string]interface{}{}, Status: http.StatusOK, Response: DeliveryHealth{}, Errors: []int{http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden, http.StatusTooManyRequests}},
+	{Method: echo.POST, Path: "/mail/events/dsn", Summary: "Apply a delivery status notification received over SMTP", Admin: true, Request: "", RequestType: "message/rfc822", Status: http.StatusOK, Response: []MailEvent{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.POST, Path: "/mail/replies", Summary: "Record a vote from a reply forwarded by a signed Mailgun route", Request: map[string]interface{}{}, RequestType: contentForm, Status: http.StatusCreated, Response: "", ResponseType: contentText, Errors: []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotAcceptable, http.StatusTooManyRequests}},
+	{Method: echo.POST, Path: "/mail/replies/raw", Summary: "Record a vote from a raw MIME reply", Admin: true, Request: "", RequestType: "message/rfc822", Status: http.StatusCreated, Response: "", ResponseType: contentText, Errors: []int{http.StatusNotAcceptable}},
+	{Method: echo.DELETE, Path: "/subscribers/:uuid", Summary: "Erase a subscriber and their personal data", Admin: true, Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/admin/audit", Summary: "List the audit trail", Admin: true, Status: http.StatusOK, Response: []AuditEntry{}},
+	{Method: echo.POST, Path: "/admin/purge", Summary: "Purge records past their retention period", Admin: true, Query: []string{"dry-run"}, Status: http.StatusOK, Response: PurgeReport{}},
+	{Method: echo.GET, Path: "/admin/backup", Summary: "Download a consistent database snapshot", Admin: true, Status: http.StatusOK, ResponseType: contentData},
+	{Method: echo.GET, Path: "/admin/tokens", Summary: "List the API tokens of this organization without their secrets", Admin: true, Status: http.StatusOK, Response: []OrganizationToken{}},
+	{Method: echo.POST, Path: "/admin/tokens", Summary: "Create an API token that only grants admin access to this organization, answering with its secret", Admin: true, Request: OrganizationToken{}, Status: http.StatusCreated, Response: OrganizationToken{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.DELETE, Path: "/admin/tokens/:id", Summary: "Revoke an API token of this organization", Admin: true, Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/moods", Summary: "List the published moods, one page of whole months at a time", Query: []string{"limit", "cursor", "order", "from", "to"}, Status: http.StatusOK, Response: []PublishedMoods{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.GET, Path: "/moods/stream", Summary: "Stream published moods as server-sent events once a closed day completes a day, week or month above the anonymity threshold, and the response count of a day above the threshold whenever a vote is recorded", Query: []string{"last-event-id"}, Status: http.StatusOK, Response: PublishedMoods{}, ResponseType: contentEventStream, Errors: []int{http.StatusTooManyRequests, http.StatusServiceUnavailable}},
+	{Method: echo.GET, Path: "/moods/:key", Summary: "Render the survey form for a feedback key", Status: http.StatusOK, Response: "", ResponseType: contentHtml, Errors: []int{http.StatusNotFound, http.StatusForbidden, http.StatusTooManyRequests}},
+	{Method: echo.POST, Path: "/moods/:key", Summary: "Answer the survey for a feedback key", Request: Mood{}, RequestType: contentForm, Status: http.StatusCreated, Response: "", ResponseType: contentText, Errors: []int{http.StatusBadRequest, http.StatusNotFound, http.StatusForbidden, http.StatusTooManyRequests}},
+	{Method: echo.GET, Path: "/surveys", Summary: "List the latest version of every survey", Status: http.StatusOK, Response: []Survey{}},
+	{Method: echo.GET, Path: "/surveys/:id", Summary: "Get a survey", Query: []string{"version"}, Status: http.StatusOK, Response: Survey{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/surveys/:id/results", Summary: "List the published results of a survey, leaving out free-text answers", Status: http.StatusOK, Response: []SurveyResults{}},
+	{Method: echo.POST, Path: "/surveys", Summary: "Create a survey", Admin: true, Request: Survey{}, Status: http.StatusCreated, Response: Survey{}, Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{Method: echo.PUT, Path: "/surveys/:id", Summary: "Create a new version of a survey", Admin: true, Request: Survey{}, Status: http.StatusCreated, Response: Survey{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+	{Method: echo.POST, Path: "/surveys/:id/activate", Summary: "Make a survey the one sent out", Admin: true, Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/admin/responses", Summary: "List the raw responses of a day", Admin: true, Query: []string{"date"}, Status: http.StatusOK, Response: []Response{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.DELETE, Path: "/admin/responses/:id", Summary: "Delete a response and rebuild its rollup", Admin: true, Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+	{Method: echo.POST, Path: "/admin/rollups/rebuild", Summary: "Rebuild daily rollups from the raw responses", Admin: true, Query: []string{"date"}, Status: http.StatusOK, Response: RebuildReport{}},
+	{Method: echo.GET, Path: "/alerts", Summary: "List the alert states", Admin: true, Status: http.StatusOK, Response: []Alert{}},
+	{Method: echo.GET, Path: "/alerts/rules", Summary: "List the alert rules", Admin: true, Status: http.StatusOK, Response: []AlertRule{}},
+	{Method: echo.POST, Path: "/alerts/rules", Summary: "Create an alert rule", Admin: true, Request: AlertRule{}, Status: http.StatusCreated, Response: AlertRule{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.DELETE, Path: "/alerts/rules/:id", Summary: "Delete an alert rule", Admin: true, Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/webhooks", Summary: "List the webhook subscriptions without their secrets", Admin: true, Status: http.StatusOK, Response: []Webhook{}},
+	{Method: echo.POST, Path: "/webhooks", Summary: "Subscribe a url to events, answering with its signing secret", Admin: true, Request: Webhook{}, Status: http.StatusCreated, Response: Webhook{}, Errors: []int{http.StatusBadRequest}},
+	{Method: echo.DELETE, Path: "/webhooks/:id", Summary: "Delete a webhook subscription", Admin: true, Status: http.StatusNoContent, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/webhooks/:id/deliveries", Summary: "List the delivery history of a webhook", Admin: true, Status: http.StatusOK, Response: []WebhookDelivery{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.POST, Path: "/webhooks/:id/deliveries/:delivery/redeliver", Summary: "Queue a delivery to be sent again", Admin: true, Status: http.StatusAccepted, Response: WebhookDelivery{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.GET, Path: "/organizations", Summary: "List the organizations hosted next to the default one, each served below /orgs/{id}", Admin: true, Status: http.StatusOK, Response: []Organization{}},
+	{Method: echo.POST, Path: "/organizations", Summary: "Create an organization with its own database, mail settings and schedule", Admin: true, Request: Organization{}, Status: http.StatusCreated, Response: Organization{}, Errors: []int{http.StatusBadRequest, http.StatusConflict}},
+	{Method: echo.GET, Path: "/organizations/:id", Summary: "Get an organization", Admin: true, Status: http.StatusOK, Response: Organization{}, Errors: []int{http.StatusNotFound}},
+	{Method: echo.PUT, Path: "/organizations/:id", Summary: "Change the name, mail settings or schedule of an organization", Admin: true, Request: Organization{}, Status: http.StatusOK, Response: Organization{}, Errors: []int{http.StatusBadRequest, http.StatusNotFound}},
+}
+
+func newSchemaRegistry() *schemaRegistry {
+	return &schemaRegistry{map[string]interface{}{}}
+}
+
+func (registry *schemaRegistry) schemaOf(value interface{}) interface{} {
+	return registry.schemaOfType(reflect.TypeOf(value))
+}
+
+func (registry *schemaRegistry) schemaOfType(valueType reflect.Type) interface{} {
+	if valueType.Kind() == reflect.Ptr {
+		valueType = valueType.Elem()
+	}
+
+	if valueType == reflect.TypeOf(time.Time{}) {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch valueType.Kind() {
+	case reflect.Struct:
+		if valueType.Name() == "" {
+			return registry.objectSchema(valueType)
+		}
+		if _, defined := registry.schemas[valueType.Name()]; !defined {
+			registry.schemas[valueType.Name()] = nil
+			registry.schemas[valueType.Name()] = registry.objectSchema(valueType)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + valueType.Name()}
+	case reflect.Slice, reflect.Array:
+		return map[string]interface{}{"type": "array", "items": registry.schemaOfType(valueType.Elem())}
+	case reflect.Map:
+		if valueType.Elem().Kind() == reflect.Interface {
+			return map[string]interface{}{"type": "object"}
+		}
+		return map[string]interface{}{"type": "object", "additionalProperties": registry.schemaOfType(valueType.Elem())}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64, reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]interface{}{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	}
+
+	return map[string]interface{}{}
+}
+
+func (registry *schemaRegistry) objectSchema(structType reflect.Type) interface{} {
+	properties := map[string]interface{}{}
+	required := []string{}
+	embedded := []interface{}{}
+
+	for index := 0; index < structType.NumField(); index++ {
+		field := structType.Field(index)
+
+		if field.PkgPath != "" && !field.Anonymous {
+			continue
+		}
+
+		tag := strings.Split(field.Tag.Get("json"), ",")
+
+		if tag[0] == "-" {
+			continue
+		}
+
+		if field.Anonymous && tag[0] == "" {
+			embedded = append(embedded, registry.schemaOfType(field.Type))
+			continue
+		}
+
+		name := tag[0]
+		if name == "" {
+			name = field.Name
+		}
+
+		properties[name] = registry.schemaOfType(field.Type)
+
+		if len(tag) == 1 || tag[1] != "omitempty" {
+			required = append(required, name)
+		}
+	}
+
+	object := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		object["required"] = required
+	}
+
+	if len(embedded) > 0 {
+		return map[string]interface{}{"allOf": append(embedded, object)}
+	}
+	return object
+}
+
+func getOpenApiPath(path string) string {
+	segments := strings.Split(path, "/")
+
+	for index, segment := range segments {
+		if strings.HasPrefix(segment, ":") {
+			segments[index] = "{" + segment[1:] + "}"
+		}
+	}
+
+	return strings.Join(segments, "/")
+}
+
+func getPathParameters(path string) (parameters []string) {
+	for _, segment := range strings.Split(path, "/") {
+		if strings.HasPrefix(segment, ":") {
+			parameters = append(parameters, segment[1:])
+		}
+	}
+	return parameters
+}
+
+func (operation apiOperation) describe(registry *schemaRegistry) map[string]interface{} {
+	parameters := []interface{}{}
+
+	for _, name := range getPathParameters(operation.Path) {
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": "path", "required": true, "schema": map[string]interface{}{"type": "string"}})
+	}
+
+	for _, name := range operation.Query {
+		parameters = append(parameters, map[string]interface{}{"name": name, "in": "query", "required": false, "schema": map[string]interface{}{"type": "string"}})
+	}
+
+	success := map[string]interface{}{"description": http.StatusText(operation.Status)}
+
+	if operation.isPaginated() {
+		success["headers"] = map[string]interface{}{
+			"Link":          map[string]interface{}{"description": "Links to the first and the next page", "schema": map[string]interface{}{"type": "string"}},
+			"X-Total-Count": map[string]interface{}{"description": "Number of records matching the filters", "schema": map[string]interface{}{"type": "integer"}},
+		}
+	}
+
+	if operation.ResponseType == contentData {
+		success["content"] = map[string]interface{}{contentData: map[string]interface{}{"schema": map[string]interface{}{"type": "string", "format": "binary"}}}
+	} else if operation.Response != nil {
+		success["content"] = map[string]interface{}{operation.getResponseType(): map[string]interface{}{"schema": registry.schemaOf(operation.Response)}}
+	}
+
+	responses := map[string]interface{}{fmt.Sprintf("%d", operation.Status): success}
+
+	errors := operation.Errors
+	if operation.Admin {
+		errors = append([]int{http.StatusUnauthorized, http.StatusForbidden}, errors...)
+	}
+
+	for _, status := range errors {
+		responses[fmt.Sprintf("%d", status)] = map[string]interface{}{
+			"description": http.StatusText(status),
+			"content":     map[string]interface{}{contentText: map[string]interface{}{"schema": map[string]interface{}{"type": "string"}}},
+		}
+	}
+
+	described := map[string]interface{}{
+		"operationId": operation.getOperationId(),
+		"summary":     operation.Summary,
+		"parameters":  parameters,
+		"responses":   responses,
+	}
+
+	if operation.Request != nil {
+		requestType := operation.RequestType
+		if requestType == "" {
+			requestType = contentJson
+		}
+		described["requestBody"] = map[string]interface{}{
+			"required": true,
+			"content":  map[string]interface{}{requestType: map[string]interface{}{"schema": registry.schemaOf(operation.Request)}},
+		}
+	}
+
+	if operation.Admin {
+		described["security"] = []interface{}{map[string]interface{}{"adminToken": []string{}}}
+	}
+
+	return described
+}
+
+func (operation apiOperation) isPaginated() bool {
+	for _, name := range operation.Query {
+		if name == "cursor" {
+			return true
+		}
+	}
+	return false
+}
+
+func (operation apiOperation) getResponseType() string {
+	if operation.ResponseType == "" {
+		return contentJson
+	}
+	return operation.ResponseType
+}
+
+func (operation apiOperation) getOperationId() string {
+	words := []string{strings.ToLower(operation.Method)}
+
+	for _, segment := range strings.FieldsFunc(operation.Path, func(character rune) bool {
+		return character == '/' || character == '.' || character == '-'
+	}) {
+		if strings.HasPrefix(segment, ":") {
+			segment = "by-" + segment[1:]
+		}
+		for _, word := range strings.Split(segment, "-") {
+			words = append(words, strings.ToUpper(word[:1])+word[1:])
+		}
+	}
+
+	return strings.Join(words, "")
+}
+
+func getRouteName(method string, path string) string {
+	return method + " " + path
+}
+
+func createOpenApiDocument() map[string]interface{} {
+	registry := newSchemaRegistry()
+	paths := map[string]interface{}{}
+
+	for _, operation := range apiOperations {
+		path := getOpenApiPath(operation.Path)
+
+		if _, found := paths[path]; !found {
+			paths[path] = map[string]interface{}{}
+		}
+		paths[path].(map[string]interface{})[strings.ToLower(operation.Method)] = operation.describe(registry)
+	}
+
+	return map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "mutservice",
+			"version": "1",
+		},
+		"servers": []interface{}{map[string]interface{}{"url": BaseUrl}},
+		"paths":   paths,
+		"components": map[string]interface{}{
+			"schemas": registry.schemas,
+			"securitySchemes": map[string]interface{}{
+				"adminToken": map[string]interface{}{"type": "http", "scheme": "bearer"},
+			},
+		},
+	}
+}
+
+func getOpenApiDocument() echo.HandlerFunc {
+	document := createOpenApiDocument()
+
+	return (func(context echo.Context) error {
+		return context.JSON(http.StatusOK, document)
+	})
+}
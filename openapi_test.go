@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var updateOpenApi = flag.Bool("update-openapi", false, "rewrite testdata/openapi.json from the documented operations")
+
+var openApiDocumentPath = filepath.Join("testdata", "openapi.json")
+
+func normalizeJson(t *testing.T, value interface{}) (normalized map[string]interface{}) {
+	encoded, jsonError := json.Marshal(value)
+
+	if jsonError == nil {
+		jsonError = json.Unmarshal(encoded, &normalized)
+	}
+
+	if jsonError != nil {
+		t.Fatal(jsonError)
+	}
+
+	delete(normalized, "servers")
+	return normalized
+}
+
+func TestOpenApiRouteCoverage(t *testing.T) {
+	server := initServer(nil, newMemoryRepositories())
+	mountOrganizations(server, nil)
+
+	documented := map[string]bool{}
+	registered := map[string]bool{}
+
+	for _, operation := range apiOperations {
+		documented[getRouteName(operation.Method, operation.Path)] = true
+	}
+
+	for _, route := range server.Routes() {
+		name := getRouteName(route.Method, route.Path)
+		registered[name] = true
+		expect(t, documented[name], "route %s is registered but not documented", name)
+	}
+
+	for name := range documented {
+		expect(t, registered[name], "route %s is documented but not registered", name)
+	}
+}
+
+func TestOpenApiDocument(t *testing.T) {
+	document := createOpenApiDocument()
+
+	if *updateOpenApi {
+		encoded, _ := json.MarshalIndent(normalizeJson(t, document), "", "  ")
+
+		if writeError := ioutil.WriteFile(openApiDocumentPath, append(encoded, '\n'), 0644); writeError != nil {
+			t.Fatal(writeError)
+		}
+	}
+
+	stored, readError := ioutil.ReadFile(openApiDocumentPath)
+
+	if readError != nil {
+		t.Fatal(readError)
+	}
+
+	var expected map[string]interface{}
+
+	if jsonError := json.Unmarshal(stored, &expected); jsonError != nil {
+		t.Fatal(jsonError)
+	}
+
+	actual := normalizeJson(t, document)
+
+	compareOpenApiSection(t, "path", lookupJson(expected, "paths"), lookupJson(actual, "paths"))
+	compareOpenApiSection(t, "schema", lookupJson(expected, "components", "schemas"), lookupJson(actual, "components", "schemas"))
+	compareOpenApiSection(t, "security scheme", lookupJson(expected, "components", "securitySchemes"), lookupJson(actual, "components", "securitySchemes"))
+}
+
+func lookupJson(document map[string]interface{}, keys ...string) map[string]interface{} {
+	for _, key := range keys {
+		document, _ = document[key].(map[string]interface{})
+	}
+	return document
+}
+
+func compareOpenApiSection(t *testing.T, kind string, expected map[string]interface{}, actual map[string]interface{}) {
+	t.Helper()
+
+	for name, value := range expected {
+		_, found := actual[name]
+		expect(t, found, "%s %s is in %s but no longer documented", kind, name, openApiDocumentPath)
+		expect(t, !found || reflect.DeepEqual(actual[name], value), "%s %s differs from %s, rerun with -update-openapi if the change is intended", kind, name, openApiDocumentPath)
+	}
+
+	for name := range actual {
+		_, found := expected[name]
+		expect(t, found, "%s %s is missing from %s, rerun with -update-openapi if the change is intended", kind, name, openApiDocumentPath)
+	}
+}
@@ -0,0 +1,478 @@
+package main
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"github.com/nu7hatch/gouuid"
+	"github.com/robfig/cron"
+	"log"
+	"net/http"
+	"net/mail"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	Organization struct {
+		Id             string    `json:"id" storm:"id"`
+		Name           string    `json:"name"`
+		MailFrom       string    `json:"mail-from,omitempty"`
+		ReplyAddress   string    `json:"reply-address,omitempty"`
+		SurveySchedule string    `json:"survey-schedule,omitempty"`
+		CreatedAt      time.Time `json:"created-at"`
+	}
+
+	OrganizationToken struct {
+		Hash      string    `json:"-" storm:"id"`
+		Id        string    `json:"id" storm:"unique"`
+		Label     string    `json:"label,omitempty"`
+		Token     string    `json:"token,omitempty"`
+		CreatedAt time.Time `json:"created-at"`
+	}
+
+	Tenant struct {
+		Organization Organization
+		database     *storm.DB
+		repositories Repositories
+		server       *echo.Echo
+		scheduler    Scheduler
+	}
+
+	Tenants struct {
+		lock         sync.RWMutex
+		root         *storm.DB
+		repositories Repositories
+		tenants      map[string]*Tenant
+		limiter      *RateLimiter
+	}
+)
+
+const (
+	organizationPrefix = "/orgs/"
+	scopeSeparator     = "_"
+)
+
+var organizationIdPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]{0,62}$`)
+
+func (organization *Organization) validate() error {
+	if !organizationIdPattern.MatchString(organization.Id) {
+		return fmt.Errorf("organization id '%s' must be lowercase letters, digits and dashes", organization.Id)
+	}
+
+	if strings.TrimSpace(organization.Name) == "" {
+		return fmt.Errorf("organization needs a name")
+	}
+
+	if organization.MailFrom != "" {
+		if _, parseError := mail.ParseAddress(organization.MailFrom); parseError != nil {
+			return fmt.Errorf("mail-from '%s' is not a valid address", organization.MailFrom)
+		}
+	}
+
+	if organization.ReplyAddress != "" {
+		if _, parseError := mail.ParseAddress(organization.ReplyAddress); parseError != nil {
+			return fmt.Errorf("reply-address '%s' is not a valid address", organization.ReplyAddress)
+		}
+	}
+
+	if organization.SurveySchedule != "" {
+		if _, parseError := cron.Parse(organization.SurveySchedule); parseError != nil {
+			return fmt.Errorf("survey-schedule '%s' is not a valid cron spec", organization.SurveySchedule)
+		}
+	}
+
+	return nil
+}
+
+func getOrganizationId(organization *Organization) string {
+	if organization == nil {
+		return ""
+	}
+	return organization.Id
+}
+
+func getSurveySchedule(organization *Organization) string {
+	if organization == nil || organization.SurveySchedule == "" {
+		return SurveySchedule
+	}
+	return organization.SurveySchedule
+}
+
+func getBackupDirectory(organization *Organization) string {
+	if organization == nil {
+		return BackupDirectory
+	}
+	return filepath.Join(BackupDirectory, organization.Id)
+}
+
+func getOrganizationDatabasePath(id string) string {
+	return getDataDirectory() + "app-mut-" + id + ".db"
+}
+
+func scopeKey(organizationId string, key string) string {
+	if organizationId == "" {
+		return key
+	}
+	return organizationId + scopeSeparator + key
+}
+
+func splitScopedKey(scoped string) (organizationId string, key string) {
+	if separator := strings.Index(scoped, scopeSeparator); separator > 0 {
+		return scoped[:separator], scoped[separator+1:]
+	}
+	return "", scoped
+}
+
+func hashToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func isOrganizationToken(database *storm.DB, authorization string) bool {
+	if database == nil || !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+
+	return database.One("Hash", hashToken(strings.TrimPrefix(authorization, "Bearer ")), new(OrganizationToken)) == nil
+}
+
+func openTenants(root *storm.DB, repositories Repositories) (tenants *Tenants, databaseError error) {
+	tenants = &Tenants{root: root, repositories: repositories, tenants: map[string]*Tenant{}, limiter: newRateLimiter(RateLimitPerMinute, RateLimitBurst, repositories.Clock)}
+	var organizations []Organization
+
+	if databaseError = root.All(&organizations); databaseError != nil {
+		return nil, databaseError
+	}
+
+	for _, organization := range organizations {
+		if _, databaseError = tenants.open(organization); databaseError != nil {
+			tenants.Close()
+			return nil, databaseError
+		}
+	}
+
+	return tenants, nil
+}
+
+func (tenants *Tenants) open(organization Organization) (tenant *Tenant, databaseError error) {
+	tenant = &Tenant{Organization: organization}
+
+	if tenant.database, databaseError = openDatabase(getOrganizationDatabasePath(organization.Id), tenants.repositories.Clock); databaseError != nil {
+		return nil, databaseError
+	}
+
+	if databaseError = tenant.start(tenants.repositories); databaseError != nil {
+		tenant.database.Close()
+		return nil, databaseError
+	}
+
+	tenants.lock.Lock()
+	tenants.tenants[organization.Id] = tenant
+	tenants.lock.Unlock()
+
+	log.Println("Opened organization " + organization.Id + ".")
+	return tenant, nil
+}
+
+func (tenant *Tenant) start(root Repositories) error {
+	tenant.repositories = newStormRepositories(tenant.database)
+	tenant.repositories.Organization, tenant.repositories.Clock, tenant.repositories.Mailer = &tenant.Organization, root.Clock, root.Mailer
+	tenant.server = initServer(tenant.database, tenant.repositories)
+	tenant.scheduler = newCronScheduler()
+
+	if scheduleError := scheduleJobs(tenant.scheduler, tenant.database, tenant.repositories); scheduleError != nil {
+		return scheduleError
+	}
+
+	tenant.scheduler.Start()
+	return nil
+}
+
+func (tenants *Tenants) Get(id string) *Tenant {
+	if tenants == nil {
+		return nil
+	}
+
+	tenants.lock.RLock()
+	defer tenants.lock.RUnlock()
+
+	return tenants.tenants[id]
+}
+
+func (tenants *Tenants) All() (organizations []Organization) {
+	tenants.lock.RLock()
+	defer tenants.lock.RUnlock()
+
+	organizations = []Organization{}
+	for _, tenant := range tenants.tenants {
+		organizations = append(organizations, tenant.Organization)
+	}
+	return organizations
+}
+
+func (tenants *Tenants) update(organization Organization) (tenant *Tenant, databaseError error) {
+	current := tenants.Get(organization.Id)
+
+	if current == nil {
+		return nil, ErrNotFound
+	}
+
+	if databaseError = tenants.root.Save(&organization); databaseError != nil {
+		return nil, databaseError
+	}
+
+	current.scheduler.Stop()
+	tenant = &Tenant{Organization: organization, database: current.database}
+
+	if databaseError = tenant.start(tenants.repositories); databaseError != nil {
+		return nil, databaseError
+	}
+
+	tenants.lock.Lock()
+	tenants.tenants[organization.Id] = tenant
+	tenants.lock.Unlock()
+
+	return tenant, nil
+}
+
+func (tenants *Tenants) Close() {
+	tenants.lock.Lock()
+	defer tenants.lock.Unlock()
+
+	for id, tenant := range tenants.tenants {
+		tenant.scheduler.Stop()
+		tenant.database.Close()
+		delete(tenants.tenants, id)
+	}
+}
+
+func getRequestOrganization(context echo.Context) string {
+	request := context.Request()
+	path := request.URL().Path()
+
+	for _, prefix := range []string{"/moods/", "/preferences/"} {
+		if strings.HasPrefix(path, prefix) {
+			organizationId, _ := splitScopedKey(path[len(prefix):])
+			return organizationId
+		}
+	}
+
+	if request.Method() != echo.POST {
+		return ""
+	}
+
+	switch path {
+	case "/mail/replies":
+		for _, key := range getMailgunReply(context).keyCandidates() {
+			if organizationId, _ := splitScopedKey(key); organizationId != "" {
+				return organizationId
+			}
+		}
+	case "/mail/events/mailgun":
+		if event, parseError := getMailgunEvent(context); parseError == nil {
+			return event.EventData.UserVariables[MailOrganizationVariable]
+		}
+	}
+
+	return ""
+}
+
+func (tenants *Tenants) getMailgunHandler(tenant *Tenant, method string, path string) echo.HandlerFunc {
+	if method != echo.POST {
+		return nil
+	}
+
+	switch path {
+	case "/mail/replies":
+		return rateLimit(tenants.limiter, "POST /mail/replies")(postMailgunReply(tenant.database, tenant.repositories))
+	case "/mail/events/mailgun":
+		return rateLimit(tenants.limiter, "POST /mail/events/mailgun")(postMailgunEvent(tenant.database, tenant.repositories))
+	}
+	return nil
+}
+
+func routeOrganizations(tenants *Tenants) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(context echo.Context) error {
+			url := context.Request().URL()
+			organizationId, path := "", url.Path()
+
+			if strings.HasPrefix(path, organizationPrefix) {
+				organizationId, path = strings.TrimPrefix(path, organizationPrefix), "/"
+
+				if slash := strings.Index(organizationId, "/"); slash >= 0 {
+					organizationId, path = organizationId[:slash], organizationId[slash:]
+				}
+			} else if organizationId = getRequestOrganization(context); organizationId == "" {
+				return next(context)
+			}
+
+			tenant := tenants.Get(organizationId)
+
+			if tenant == nil {
+				return context.String(http.StatusNotFound, "Organization with id '"+organizationId+"' not found!")
+			}
+
+			if path == url.Path() {
+				if handler := tenants.getMailgunHandler(tenant, context.Request().Method(), path); handler != nil {
+					return handler(context)
+				}
+			}
+
+			url.SetPath(path)
+			tenant.server.ServeHTTP(context.Request(), context.Response())
+			return nil
+		}
+	}
+}
+
+func mountOrganizations(server *echo.Echo, tenants *Tenants) {
+	server.Pre(routeOrganizations(tenants))
+	server.Get("/organizations", getOrganizations(tenants), adminAuth(nil))
+	server.Post("/organizations", postOrganization(tenants), adminAuth(nil))
+	server.Get("/organizations/:id", getOrganization(tenants), adminAuth(nil))
+	server.Put("/organizations/:id", putOrganization(tenants), adminAuth(nil))
+}
+
+func getOrganizations(tenants *Tenants) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		return context.JSON(http.StatusOK, tenants.All())
+	})
+}
+
+func getOrganization(tenants *Tenants) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+
+		if tenant := tenants.Get(id); tenant != nil {
+			return context.JSON(http.StatusOK, tenant.Organization)
+		}
+		return context.String(http.StatusNotFound, "Organization with id '"+id+"' not found!")
+	})
+}
+
+func postOrganization(tenants *Tenants) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		organization := new(Organization)
+
+		if jsonError := context.Bind(organization); jsonError != nil {
+			return jsonError
+		}
+
+		if validationError := organization.validate(); validationError != nil {
+			return context.String(http.StatusBadRequest, validationError.Error())
+		}
+
+		if tenants.Get(organization.Id) != nil {
+			return context.String(http.StatusConflict, "Organization with id '"+organization.Id+"' already exists!")
+		}
+
+		organization.CreatedAt = tenants.repositories.Clock.Now()
+
+		if databaseError := tenants.root.Save(organization); databaseError != nil {
+			return databaseError
+		}
+
+		if _, databaseError := tenants.open(*organization); databaseError != nil {
+			return databaseError
+		}
+
+		return context.JSON(http.StatusCreated, organization)
+	})
+}
+
+func putOrganization(tenants *Tenants) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		current := tenants.Get(id)
+
+		if current == nil {
+			return context.String(http.StatusNotFound, "Organization with id '"+id+"' not found!")
+		}
+
+		organization := new(Organization)
+
+		if jsonError := context.Bind(organization); jsonError != nil {
+			return jsonError
+		}
+
+		organization.Id = id
+		organization.CreatedAt = current.Organization.CreatedAt
+
+		if validationError := organization.validate(); validationError != nil {
+			return context.String(http.StatusBadRequest, validationError.Error())
+		}
+
+		if tenant, databaseError := tenants.update(*organization); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, tenant.Organization)
+		}
+	})
+}
+
+func getOrganizationTokens(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		tokens := []OrganizationToken{}
+
+		if databaseError := database.All(&tokens); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, tokens)
+		}
+	})
+}
+
+func postOrganizationToken(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		token := new(OrganizationToken)
+
+		if jsonError := context.Bind(token); jsonError != nil {
+			return jsonError
+		}
+
+		secret := make([]byte, 32)
+		if _, randomError := rand.Read(secret); randomError != nil {
+			return randomError
+		}
+
+		id, _ := uuid.NewV4()
+		token.Id = id.String()
+		token.Token = hex.EncodeToString(secret)
+		token.Hash = hashToken(token.Token)
+		token.CreatedAt = repositories.Clock.Now()
+
+		stored := *token
+		stored.Token = ""
+
+		if databaseError := database.Save(&stored); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusCreated, token)
+		}
+	})
+}
+
+func deleteOrganizationToken(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		token := new(OrganizationToken)
+
+		if databaseError := database.One("Id", id, token); databaseError != nil {
+			return context.String(http.StatusNotFound, "Token with id '"+id+"' not found!")
+		}
+
+		if databaseError := database.Remove(token); databaseError != nil {
+			return databaseError
+		}
+
+		return context.NoContent(http.StatusNoContent)
+	})
+}
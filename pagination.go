@@ -0,0 +1,251 @@
+package main
+
+import (
+	"encoding/base64"
+	"github.com/labstack/echo"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type (
+	Page struct {
+		Cursor  string
+		Limit   int
+		Reverse bool
+	}
+
+	SubscriberFilter struct {
+		EmailPrefix string
+		Status      string
+	}
+
+	MoodFilter struct {
+		From time.Time
+		To   time.Time
+	}
+
+	paginationError struct {
+		message string
+	}
+)
+
+const lastKey = "\xff"
+const monthLayout = "2006-01"
+
+var SubscriberPageLimit int = getEnvInt("MUT_SUBSCRIBER_PAGE_LIMIT", 50)
+var SubscriberPageMaximum int = getEnvInt("MUT_SUBSCRIBER_PAGE_MAXIMUM", 500)
+var MoodPageMonths int = getEnvInt("MUT_MOOD_PAGE_MONTHS", 12)
+var MoodPageMaximumMonths int = getEnvInt("MUT_MOOD_PAGE_MAXIMUM_MONTHS", 60)
+
+func (paginationError *paginationError) Error() string {
+	return paginationError.message
+}
+
+func (filter SubscriberFilter) matches(subscriber Subscriber) bool {
+	return strings.HasPrefix(subscriber.Email, filter.EmailPrefix) && (filter.Status == "" || subscriber.Status == filter.Status)
+}
+
+func (page Page) isAfter(key string) bool {
+	if page.Cursor == "" {
+		return true
+	} else if page.Reverse {
+		return key < page.Cursor
+	} else {
+		return key > page.Cursor
+	}
+}
+
+func encodeCursor(key string) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(key))
+}
+
+func decodeCursor(cursor string) (string, error) {
+	key, decodeError := base64.RawURLEncoding.DecodeString(cursor)
+
+	if decodeError != nil {
+		return "", &paginationError{"Query parameter 'cursor' is invalid!"}
+	}
+	return string(key), nil
+}
+
+func parsePage(context echo.Context, defaultLimit int, maximumLimit int) (page Page, parseError error) {
+	page.Limit = defaultLimit
+
+	if limit := context.QueryParam("limit"); limit != "" {
+		if page.Limit, parseError = strconv.Atoi(limit); parseError != nil || page.Limit < 1 || page.Limit > maximumLimit {
+			return page, &paginationError{"Query parameter 'limit' must be between 1 and " + strconv.Itoa(maximumLimit) + "!"}
+		}
+	}
+
+	switch context.QueryParam("order") {
+	case "", "asc":
+	case "desc":
+		page.Reverse = true
+	default:
+		return page, &paginationError{"Query parameter 'order' must be 'asc' or 'desc'!"}
+	}
+
+	if cursor := context.QueryParam("cursor"); cursor != "" {
+		if page.Cursor, parseError = decodeCursor(cursor); parseError != nil {
+			return page, parseError
+		}
+	}
+
+	return page, nil
+}
+
+func parseMoodFilter(context echo.Context) (filter MoodFilter, parseError error) {
+	for name, target := range map[string]*time.Time{"from": &filter.From, "to": &filter.To} {
+		if value := context.QueryParam(name); value != "" {
+			if *target, parseError = time.Parse(dateLayout, value); parseError != nil {
+				return filter, &paginationError{"Query parameter '" + name + "' must be a date like " + dateLayout + "!"}
+			}
+		}
+	}
+
+	return filter, nil
+}
+
+func setPaginationHeaders(context echo.Context, next string, total int) {
+	response := context.Response()
+	response.Header().Set("X-Total-Count", strconv.Itoa(total))
+
+	query := url.Values(context.Request().URL().QueryParams())
+	query.Del("cursor")
+	path := BaseUrl + context.Request().URL().Path()
+
+	links := []string{`<` + path + `?` + query.Encode() + `>; rel="first"`}
+
+	if next != "" {
+		query.Set("cursor", encodeCursor(next))
+		links = append(links, `<`+path+`?`+query.Encode()+`>; rel="next"`)
+	}
+
+	response.Header().Set("Link", strings.Join(links, ", "))
+}
+
+func startOfMonth(date time.Time) time.Time {
+	return time.Date(date.Year(), date.Month(), 1, 0, 0, 0, 0, time.UTC)
+}
+
+func endOfMonth(date time.Time) time.Time {
+	return startOfMonth(date).AddDate(0, 1, -1)
+}
+
+func startOfWeek(date time.Time) time.Time {
+	return date.AddDate(0, 0, -(int(date.Weekday())+6)%7)
+}
+
+func endOfWeek(date time.Time) time.Time {
+	return startOfWeek(date).AddDate(0, 0, 6)
+}
+
+func (filter MoodFilter) bounds() (fromDay string, toDay string) {
+	fromDay, toDay = "", lastKey
+
+	if !filter.From.IsZero() {
+		fromDay = filter.From.Format(dayLayout)
+	}
+	if !filter.To.IsZero() {
+		toDay = filter.To.Format(dayLayout)
+	}
+
+	return fromDay, toDay
+}
+
+func getPublishedMoodsPage(moods MoodRepository, filter MoodFilter, page Page) (published []PublishedMoods, next string, databaseError error) {
+	fromDay, toDay := filter.bounds()
+	published = []PublishedMoods{}
+
+	start, cursorError := time.Parse(monthLayout, page.Cursor)
+
+	if page.Cursor == "" {
+		first, databaseError := moods.Between(fromDay, toDay, 1, page.Reverse)
+
+		if databaseError != nil || len(first) == 0 {
+			return published, "", databaseError
+		}
+		start = startOfMonth(first[0].Date())
+	} else if cursorError != nil {
+		return nil, "", &paginationError{"Query parameter 'cursor' is invalid!"}
+	}
+
+	months := map[string]bool{}
+	earliest, latest := start, start
+
+	for index := 0; index < page.Limit; index++ {
+		month := start.AddDate(0, index, 0)
+		if page.Reverse {
+			month = start.AddDate(0, -index, 0)
+		}
+
+		if month.Format(dayLayout) > toDay || endOfMonth(month).Format(dayLayout) < fromDay {
+			break
+		}
+
+		months[month.Format(monthLayout)] = true
+
+		if month.Before(earliest) {
+			earliest = month
+		}
+		if month.After(latest) {
+			latest = month
+		}
+	}
+
+	history, databaseError := moods.Between(startOfWeek(earliest).Format(dayLayout), endOfWeek(endOfMonth(latest)).Format(dayLayout), 0, false)
+
+	if databaseError != nil {
+		return nil, "", databaseError
+	}
+
+	for _, entry := range publishMoods(history) {
+		until, _ := time.Parse(dateLayout, entry.Until)
+
+		if months[entry.Date().Format(monthLayout)] && entry.Date().Format(dayLayout) >= fromDay && until.Format(dayLayout) <= toDay {
+			published = append(published, entry)
+		}
+	}
+
+	sort.SliceStable(published, func(i, j int) bool {
+		return published[i].Date().Before(published[j].Date()) != page.Reverse
+	})
+
+	var following []DailyMoods
+
+	if page.Reverse {
+		following, databaseError = moods.Between(fromDay, earliest.AddDate(0, 0, -1).Format(dayLayout), 1, true)
+	} else {
+		following, databaseError = moods.Between(latest.AddDate(0, 1, 0).Format(dayLayout), toDay, 1, false)
+	}
+
+	if databaseError == nil && len(following) > 0 {
+		next = startOfMonth(following[0].Date()).Format(monthLayout)
+	}
+
+	return published, next, databaseError
+}
+
+func getSubscribersPage(subscribers SubscriberRepository, filter SubscriberFilter, page Page) (result []Subscriber, next string, total int, databaseError error) {
+	if result, next, databaseError = subscribers.Page(filter, page); databaseError != nil {
+		return nil, "", 0, databaseError
+	}
+
+	if result == nil {
+		result = []Subscriber{}
+	}
+
+	total, databaseError = subscribers.Count(filter)
+	return result, next, total, databaseError
+}
+
+func respondWithPaginationError(context echo.Context, err error) error {
+	if invalid, isPaginationError := err.(*paginationError); isPaginationError {
+		return context.String(http.StatusBadRequest, invalid.message)
+	}
+	return err
+}
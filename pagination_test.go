@@ -0,0 +1,29 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMoodPageKeepsToTheDayRange(t *testing.T) {
+	moods := newMemoryRepositories().Moods
+
+	for _, dateString := range []string{"31-05-2026", "01-06-2026", "02-06-2026", "03-06-2026"} {
+		saveMoodsOfDay(t, moods, dateString, MinimumResponses)
+	}
+
+	from, _ := time.Parse(dateLayout, "01-06-2026")
+	to, _ := time.Parse(dateLayout, "02-06-2026")
+	filter := MoodFilter{from, to}
+
+	published, next, databaseError := getPublishedMoodsPage(moods, filter, Page{Limit: 1})
+	expect(t, databaseError == nil && next == "", "one month must cover the whole range, next is '%s'", next)
+	expect(t, len(published) == 2, "expected the two days in range, got %d entries", len(published))
+
+	for _, entry := range published {
+		expect(t, entry.DateString == "01-06-2026" || entry.DateString == "02-06-2026", "day %s is out of range", entry.DateString)
+	}
+
+	total, databaseError := moods.Count(filter.bounds())
+	expect(t, databaseError == nil && total == 2, "expected a total of 2 days, got %d", total)
+}
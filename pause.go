@@ -0,0 +1,327 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/labstack/echo"
+	"html"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+type (
+	PauseRequest struct {
+		From  string `json:"from"`
+		Until string `json:"until,omitempty"`
+	}
+
+	PreferenceChoice struct {
+		Action string `json:"action"`
+		Until  string `json:"until,omitempty"`
+	}
+)
+
+const (
+	PauseWeek         = "pause-week"
+	PauseTwoWeeks     = "pause-two-weeks"
+	PauseUntil        = "pause-until"
+	PauseIndefinitely = "pause-indefinitely"
+	PauseResume       = "resume"
+)
+
+var PreferenceSecret string = os.Getenv("MUT_PREFERENCE_SECRET")
+
+func validatePause(from string, until string) error {
+	if from == "" {
+		if until != "" {
+			return &ProfileError{"Field 'paused-until' requires 'paused-from'!"}
+		}
+		return nil
+	}
+
+	fromDate, parseError := time.Parse(dateLayout, from)
+
+	if parseError != nil {
+		return &ProfileError{"Pause start '" + from + "' must be formatted as dd-mm-yyyy!"}
+	}
+
+	if until == "" {
+		return nil
+	}
+
+	untilDate, parseError := time.Parse(dateLayout, until)
+
+	if parseError != nil {
+		return &ProfileError{"Pause end '" + until + "' must be formatted as dd-mm-yyyy!"}
+	}
+
+	if untilDate.Before(fromDate) {
+		return &ProfileError{"Pause end '" + until + "' lies before its start '" + from + "'!"}
+	}
+
+	return nil
+}
+
+func (subscriber *Subscriber) isPausedOn(now time.Time) bool {
+	if subscriber.PausedFrom == "" {
+		return false
+	}
+
+	day := now.In(getLocation(subscriber.TimeZone)).Format(dayLayout)
+	from, _ := time.Parse(dateLayout, subscriber.PausedFrom)
+
+	if day < from.Format(dayLayout) {
+		return false
+	}
+
+	if subscriber.PausedUntil == "" {
+		return true
+	}
+
+	until, _ := time.Parse(dateLayout, subscriber.PausedUntil)
+	return day <= until.Format(dayLayout)
+}
+
+func getSurveyRecipients(subscribers []Subscriber, now time.Time) (recipients []Subscriber, paused int) {
+	for _, subscriber := range subscribers {
+		if subscriber.Status != "" && subscriber.Status != SubscriberActive || !subscriber.isDueOn(now) {
+			continue
+		} else if subscriber.isPausedOn(now) {
+			paused++
+		} else {
+			recipients = append(recipients, subscriber)
+		}
+	}
+	return recipients, paused
+}
+
+func pauseSubscriber(subscribers SubscriberRepository, subscriber *Subscriber, from string, until string, now time.Time) error {
+	if validationError := validatePause(from, until); validationError != nil {
+		return validationError
+	}
+
+	subscriber.PausedFrom = from
+	subscriber.PausedUntil = until
+	subscriber.UpdatedAt = now
+
+	return subscribers.Save(subscriber)
+}
+
+func createPreferenceToken(uuid string) string {
+	if PreferenceSecret == "" {
+		return ""
+	}
+	return uuid + "." + signPreferences(uuid)
+}
+
+func signPreferences(uuid string) string {
+	mac := hmac.New(sha256.New, []byte(PreferenceSecret))
+	mac.Write([]byte(uuid))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func parsePreferenceToken(token string) (uuid string, valid bool) {
+	separator := strings.LastIndex(token, ".")
+
+	if PreferenceSecret == "" || separator < 0 {
+		return "", false
+	}
+
+	uuid = token[:separator]
+	return uuid, hmac.Equal([]byte(token[separator+1:]), []byte(signPreferences(uuid)))
+}
+
+func getPreferenceUrl(uuid string) string {
+	if token := createPreferenceToken(uuid); token != "" {
+		return BaseUrl + "/preferences/" + token
+	}
+	return ""
+}
+
+func postPause(repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		uuid := context.Param("uuid")
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
+
+		if databaseError == ErrNotFound {
+			return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		body, readError := ioutil.ReadAll(context.Request().Body())
+
+		if readError != nil {
+			return readError
+		}
+
+		pause := PauseRequest{}
+
+		if jsonError := json.Unmarshal(body, &pause); jsonError != nil {
+			return context.String(http.StatusBadRequest, "Body is not a valid pause request!")
+		}
+
+		if pause.From == "" {
+			pause.From = repositories.Clock.Now().In(getLocation(subscriber.TimeZone)).Format(dateLayout)
+		}
+
+		databaseError = pauseSubscriber(repositories.Subscribers, subscriber, pause.From, pause.Until, repositories.Clock.Now())
+
+		if profileError, invalid := databaseError.(*ProfileError); invalid {
+			return context.String(http.StatusBadRequest, profileError.Message)
+		} else if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, subscriber)
+		}
+	})
+}
+
+func deletePause(repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		uuid := context.Param("uuid")
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
+
+		if databaseError == ErrNotFound {
+			return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		if databaseError = pauseSubscriber(repositories.Subscribers, subscriber, "", "", repositories.Clock.Now()); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, subscriber)
+		}
+	})
+}
+
+func getPreferencesForm(repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		locale := getRequestLocale(context)
+		subscriber, found, databaseError := getPreferenceSubscriber(repositories, context.Param("token"))
+
+		if databaseError != nil {
+			return databaseError
+		} else if !found {
+			return context.String(http.StatusNotFound, translate(locale, "pause.not-found"))
+		}
+
+		return context.HTML(http.StatusOK, getPreferencesHtmlText(context.Param("token"), subscriber, getLocale(subscriber.Locale), ""))
+	})
+}
+
+func postPreferences(repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		token := context.Param("token")
+		locale := getRequestLocale(context)
+		subscriber, found, databaseError := getPreferenceSubscriber(repositories, token)
+
+		if databaseError != nil {
+			return databaseError
+		} else if !found {
+			return context.String(http.StatusNotFound, translate(locale, "pause.not-found"))
+		}
+
+		locale = getLocale(subscriber.Locale)
+		today := repositories.Clock.Now().In(getLocation(subscriber.TimeZone))
+		from, until := today.Format(dateLayout), ""
+
+		switch context.FormValue("action") {
+		case PauseWeek:
+			until = today.AddDate(0, 0, 6).Format(dateLayout)
+		case PauseTwoWeeks:
+			until = today.AddDate(0, 0, 13).Format(dateLayout)
+		case PauseUntil:
+			if date, parseError := time.Parse(dayLayout, context.FormValue("until")); parseError == nil {
+				until = date.Format(dateLayout)
+			} else {
+				return context.HTML(http.StatusBadRequest, getPreferencesHtmlText(token, subscriber, locale, translate(locale, "pause.invalid")))
+			}
+		case PauseIndefinitely:
+		case PauseResume:
+			from = ""
+		default:
+			return context.HTML(http.StatusBadRequest, getPreferencesHtmlText(token, subscriber, locale, translate(locale, "pause.invalid")))
+		}
+
+		databaseError = pauseSubscriber(repositories.Subscribers, subscriber, from, until, repositories.Clock.Now())
+
+		if _, invalid := databaseError.(*ProfileError); invalid {
+			return context.HTML(http.StatusBadRequest, getPreferencesHtmlText(token, subscriber, locale, translate(locale, "pause.invalid")))
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		return context.HTML(http.StatusOK, getPreferencesHtmlText(token, subscriber, locale, translate(locale, "pause.saved")))
+	})
+}
+
+func getPreferenceSubscriber(repositories Repositories, token string) (subscriber *Subscriber, found bool, databaseError error) {
+	scoped, valid := parsePreferenceToken(token)
+	organizationId, uuid := splitScopedKey(scoped)
+
+	if !valid || organizationId != getOrganizationId(repositories.Organization) {
+		return nil, false, nil
+	}
+
+	subscriber, databaseError = getSubscriberByUuid(repositories.Subscribers, uuid)
+
+	if databaseError == ErrNotFound {
+		return nil, false, nil
+	}
+
+	return subscriber, databaseError == nil, databaseError
+}
+
+func getPauseState(subscriber *Subscriber, locale string) string {
+	if subscriber.PausedFrom == "" {
+		return translate(locale, "pause.state.active")
+	}
+
+	from, _ := time.Parse(dateLayout, subscriber.PausedFrom)
+
+	if subscriber.PausedUntil == "" {
+		return translate(locale, "pause.state.indefinite", formatLongDate(locale, from))
+	}
+
+	until, _ := time.Parse(dateLayout, subscriber.PausedUntil)
+	return translate(locale, "pause.state.until", formatLongDate(locale, from), formatLongDate(locale, until))
+}
+
+func getPreferencesHtmlText(token string, subscriber *Subscriber, locale string, notice string) string {
+	action := html.EscapeString(BaseUrl + "/preferences/" + token)
+	buttons := make([]string, 0, 4)
+
+	for _, choice := range []string{PauseWeek, PauseTwoWeeks, PauseIndefinitely, PauseResume} {
+		buttons = append(buttons, `<form method="POST" action="`+action+`">
+	<input type="hidden" name="action" value="`+choice+`">
+	<input type="submit" value="`+html.EscapeString(translate(locale, "pause."+choice))+`">
+	</form>`)
+	}
+
+	if notice != "" {
+		notice = `<p>` + html.EscapeString(notice) + `</p>`
+	}
+
+	return `<html lang="` + locale + `">
+	<head><meta charset="utf-8"></head>
+	<body>
+	<h1>` + html.EscapeString(translate(locale, "pause.title")) + `</h1>
+	` + notice + `
+	<p>` + html.EscapeString(getPauseState(subscriber, locale)) + `</p>
+	` + strings.Join(buttons, "\n\t<br/>\n\t") + `
+	<br/>
+	<form method="POST" action="` + action + `">
+	<input type="hidden" name="action" value="` + PauseUntil + `">
+	<input type="date" name="until" required>
+	<input type="submit" value="` + html.EscapeString(translate(locale, "pause."+PauseUntil)) + `">
+	</form>
+	</body>
+	</html>`
+}
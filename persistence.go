@@ -1,67 +1,458 @@
 package main
 
 import (
+	"crypto/rand"
 	"crypto/sha1"
 	"encoding/hex"
+	"encoding/json"
 	"github.com/asdine/storm"
+	"github.com/boltdb/bolt"
 	"github.com/nu7hatch/gouuid"
 	"log"
+	"math"
 	"os"
 	"strings"
 	"time"
 )
 
+const dateLayout = "02-01-2006"
+const dayLayout = "2006-01-02"
+
+const SubscriberActive = "active"
+const SubscriberSuppressed = "suppressed"
+
 type (
 	FeedbackIdentifier struct {
-		Key        string `storm:"id"`
-		DateString string `storm:"index"`
+		Key            string `storm:"id"`
+		DateString     string `storm:"index"`
+		Team           string
+		Weight         float64
+		SubscriberUuid string `storm:"index"`
 	}
 
 	DailyMoods struct {
-		DateString  string `json:"date" storm:"id"`
-		VeryUnhappy int `json:"very-unhappy"`
-		Unhappy     int `json:"unhappy"`
-		Neutral     int `json:"neutral"`
-		Happy       int `json:"happy"`
-		VeryHappy   int `json:"very-happy"`
+		DateString    string          `json:"date" storm:"id"`
+		Day           string          `json:"-" storm:"index"`
+		SurveyId      string          `json:"survey,omitempty"`
+		SurveyVersion int             `json:"survey-version,omitempty"`
+		Scale         Scale           `json:"scale"`
+		Counts        map[int]int     `json:"counts"`
+		Weights       map[int]float64 `json:"weights,omitempty"`
+		Samples       []MoodSample    `json:"samples,omitempty"`
+		Invited       int             `json:"invited"`
+		Paused        int             `json:"paused,omitempty"`
+		FromResponses bool            `json:"from-responses,omitempty"`
+	}
+
+	MoodSample struct {
+		Value  int     `json:"value"`
+		Weight float64 `json:"weight"`
+		Count  int     `json:"count"`
+	}
+
+	legacyDailyMoods struct {
+		DateString  string      `json:"date"`
+		Counts      map[int]int `json:"counts"`
+		VeryUnhappy int         `json:"very-unhappy"`
+		Unhappy     int         `json:"unhappy"`
+		Neutral     int         `json:"neutral"`
+		Happy       int         `json:"happy"`
+		VeryHappy   int         `json:"very-happy"`
+		Invited     int         `json:"invited"`
 	}
 
 	Subscriber struct {
-		Uuid  string `json:"uuid" storm:"id"`
-		Email string `json:"email" storm:"unique"`
+		Uuid          string            `json:"uuid" storm:"id"`
+		Email         string            `json:"email" storm:"unique"`
+		Team          string            `json:"team,omitempty"`
+		Status        string            `json:"status" storm:"index"`
+		Name          string            `json:"name,omitempty"`
+		Locale        string            `json:"locale,omitempty"`
+		TimeZone      string            `json:"time-zone,omitempty"`
+		Channel       string            `json:"channel,omitempty"`
+		Attributes    map[string]string `json:"attributes,omitempty"`
+		NoReminders   bool              `json:"no-reminders,omitempty"`
+		PausedFrom    string            `json:"paused-from,omitempty"`
+		PausedUntil   string            `json:"paused-until,omitempty"`
+		Frequency     string            `json:"frequency,omitempty"`
+		Weekdays      []string          `json:"weekdays,omitempty"`
+		FrequencyFrom string            `json:"frequency-from,omitempty"`
+		CreatedAt     time.Time         `json:"created-at"`
+		UpdatedAt     time.Time         `json:"updated-at"`
 	}
 )
 
-func (dailyMoods *DailyMoods) AddMood(mood string) {
-	if mood == "0" {
-		dailyMoods.VeryUnhappy++
+func (dailyMoods *DailyMoods) AddMood(mood string, weight float64) error {
+	value, parseError := dailyMoods.Scale.ParseValue(mood)
+
+	if parseError != nil {
+		return parseError
+	}
+
+	if weight <= 0 {
+		weight = 1
+	}
+
+	if dailyMoods.Weights == nil {
+		dailyMoods.Weights = map[int]float64{}
+
+		for existing, count := range dailyMoods.Counts {
+			dailyMoods.Weights[existing] = float64(count)
+		}
+	}
+
+	if dailyMoods.Samples == nil {
+		dailyMoods.Samples = dailyMoods.samples()
+	}
+
+	if dailyMoods.Counts == nil {
+		dailyMoods.Counts = map[int]int{}
+	}
+	dailyMoods.Counts[value]++
+	dailyMoods.Weights[value] += weight
+	dailyMoods.addSample(value, weight)
+
+	return nil
+}
+
+func (dailyMoods *DailyMoods) addSample(value int, weight float64) {
+	for index := range dailyMoods.Samples {
+		if dailyMoods.Samples[index].Value == value && dailyMoods.Samples[index].Weight == weight {
+			dailyMoods.Samples[index].Count++
+			return
+		}
+	}
+
+	dailyMoods.Samples = append(dailyMoods.Samples, MoodSample{value, weight, 1})
+}
+
+func (dailyMoods *DailyMoods) samples() (samples []MoodSample) {
+	if len(dailyMoods.Samples) > 0 {
+		return dailyMoods.Samples
 	}
-	if mood == "1" {
-		dailyMoods.Unhappy++
+
+	for value, count := range dailyMoods.Counts {
+		if count > 0 {
+			weight := float64(count)
+
+			if dailyMoods.Weights != nil {
+				weight = dailyMoods.Weights[value]
+			}
+			samples = append(samples, MoodSample{value, weight / float64(count), count})
+		}
 	}
-	if mood == "2" {
-		dailyMoods.Neutral++
+	return samples
+}
+
+func (dailyMoods *DailyMoods) WeightOf(value int, window int) (weight float64) {
+	for _, sample := range dailyMoods.samples() {
+		if sample.Value == value {
+			weight += float64(sample.Count) * math.Min(sample.Weight, float64(window))
+		}
 	}
-	if mood == "3" {
-		dailyMoods.Happy++
+	return weight
+}
+
+func (dailyMoods *DailyMoods) TotalWeight(window int) (total float64) {
+	for value := range dailyMoods.Counts {
+		total += dailyMoods.WeightOf(value, window)
 	}
-	if mood == "4" {
-		dailyMoods.VeryHappy++
+	return total
+}
+
+func (dailyMoods *DailyMoods) WeightedSum(window int) (sum float64) {
+	for value := range dailyMoods.Counts {
+		sum += float64(value) * dailyMoods.WeightOf(value, window)
+	}
+	return sum
+}
+
+func (dailyMoods *DailyMoods) Total() (total int) {
+	for _, count := range dailyMoods.Counts {
+		total += count
 	}
+	return total
 }
 
-func createDatabase() (database *storm.DB) {
-	database, databaseError := storm.Open(getDataDirectory() + "app-mut.db")
+func (dailyMoods *DailyMoods) Sum() (sum int) {
+	for value, count := range dailyMoods.Counts {
+		sum += value * count
+	}
+	return sum
+}
+
+func (dailyMoods *DailyMoods) Lowest() int {
+	return dailyMoods.Counts[dailyMoods.Scale.Lowest()]
+}
+
+func (dailyMoods *DailyMoods) Date() time.Time {
+	date, _ := time.Parse(dateLayout, dailyMoods.DateString)
+	return date
+}
+
+func (feedbackIdentifier *FeedbackIdentifier) Date() time.Time {
+	date, _ := time.Parse(dateLayout, feedbackIdentifier.DateString)
+	return date
+}
+
+func (dailyMoods *DailyMoods) SortKey() string {
+	return dailyMoods.Date().Format(dayLayout)
+}
+
+func createDatabase(clock Clock) (database *storm.DB) {
+	database, databaseError := openDatabase(getDatabasePath(), clock)
 
 	if databaseError != nil {
 		log.Fatal(databaseError)
 	}
 
+	return database
+}
+
+func openDatabase(path string, clock Clock) (database *storm.DB, databaseError error) {
+	database, databaseError = storm.Open(path)
+
+	if databaseError != nil {
+		return nil, databaseError
+	}
+
 	_ = database.Init(&Subscriber{})
 	_ = database.Init(&FeedbackIdentifier{})
 	_ = database.Init(&DailyMoods{})
+	_ = database.Init(&AlertRule{})
+	_ = database.Init(&Alert{})
+	_ = database.Init(&Survey{})
+	_ = database.Init(&SurveyResults{})
+	_ = database.Init(&Response{})
+	_ = database.Init(&MailDelivery{})
+	_ = database.Init(&AuditEntry{})
+	_ = database.Init(&Webhook{})
+	_ = database.Init(&WebhookDelivery{})
+	_ = database.Init(&DeliveryHealth{})
+	_ = database.Init(&Organization{})
+	_ = database.Init(&OrganizationToken{})
+
+	if databaseError = migrateDailyMoods(database); databaseError != nil {
+		database.Close()
+		return nil, databaseError
+	}
 
-	return database
+	if databaseError = ensureDefaultSurvey(database, clock); databaseError != nil {
+		database.Close()
+		return nil, databaseError
+	}
+
+	if databaseError = migrateSortKeys(database); databaseError != nil {
+		database.Close()
+		return nil, databaseError
+	}
+
+	if databaseError = migrateSubscriberDates(database, clock); databaseError != nil {
+		database.Close()
+		return nil, databaseError
+	}
+
+	if databaseError = migrateFeedbackKeys(database); databaseError != nil {
+		database.Close()
+		return nil, databaseError
+	}
+
+	return database, nil
+}
+
+func migrateDailyMoods(database *storm.DB) (databaseError error) {
+	var legacyRecords []legacyDailyMoods
+
+	databaseError = database.Bolt.View(func(transaction *bolt.Tx) error {
+		bucket := transaction.Bucket([]byte("DailyMoods"))
+
+		if bucket == nil {
+			return nil
+		}
+
+		return bucket.ForEach(func(key []byte, value []byte) error {
+			if value == nil {
+				return nil
+			}
+
+			legacy := legacyDailyMoods{}
+
+			if jsonError := json.Unmarshal(value, &legacy); jsonError != nil {
+				return jsonError
+			}
+
+			if legacy.Counts == nil {
+				legacyRecords = append(legacyRecords, legacy)
+			}
+
+			return nil
+		})
+	})
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	for _, legacy := range legacyRecords {
+		dailyMoods := DailyMoods{
+			DateString: legacy.DateString,
+			Scale:      LegacyScale,
+			Counts: map[int]int{
+				0: legacy.VeryUnhappy,
+				1: legacy.Unhappy,
+				2: legacy.Neutral,
+				3: legacy.Happy,
+				4: legacy.VeryHappy,
+			},
+			Invited: legacy.Invited,
+		}
+
+		if databaseError = database.Save(&dailyMoods); databaseError != nil {
+			return databaseError
+		}
+	}
+
+	if len(legacyRecords) > 0 {
+		log.Printf("Migrated %d daily moods to the %s scale.", len(legacyRecords), LegacyScale.Name)
+	}
+
+	return nil
+}
+
+func migrateSortKeys(database *storm.DB) (databaseError error) {
+	var history []DailyMoods
+	var subscribers []Subscriber
+	migrated := 0
+
+	if databaseError = database.All(&history); databaseError != nil {
+		return databaseError
+	}
+
+	for index := range history {
+		if history[index].Day == "" {
+			history[index].Day = history[index].SortKey()
+
+			if databaseError = database.Save(&history[index]); databaseError != nil {
+				return databaseError
+			}
+			migrated++
+		}
+	}
+
+	if databaseError = database.All(&subscribers); databaseError != nil {
+		return databaseError
+	}
+
+	for index := range subscribers {
+		if subscribers[index].Status == "" {
+			subscribers[index].Status = SubscriberActive
+
+			if databaseError = database.Save(&subscribers[index]); databaseError != nil {
+				return databaseError
+			}
+			migrated++
+		}
+	}
+
+	if migrated > 0 {
+		log.Printf("Indexed %d daily moods and subscribers for pagination.", migrated)
+	}
+
+	return nil
+}
+
+func migrateSubscriberDates(database *storm.DB, clock Clock) (databaseError error) {
+	var subscribers []Subscriber
+	migrated := 0
+
+	if databaseError = database.All(&subscribers); databaseError != nil {
+		return databaseError
+	}
+
+	for index := range subscribers {
+		subscriber := &subscribers[index]
+		createdAt, anchor := subscriber.CreatedAt, subscriber.FrequencyFrom
+
+		if createdAt.IsZero() {
+			subscriber.CreatedAt = subscriber.UpdatedAt
+
+			if subscriber.CreatedAt.IsZero() {
+				subscriber.CreatedAt = clock.Now()
+			}
+		}
+		subscriber.anchorFrequency(subscriber.CreatedAt)
+
+		if subscriber.CreatedAt.Equal(createdAt) && subscriber.FrequencyFrom == anchor {
+			continue
+		}
+
+		if databaseError = database.Save(subscriber); databaseError != nil {
+			return databaseError
+		}
+		migrated++
+	}
+
+	if migrated > 0 {
+		log.Printf("Backfilled creation dates and biweekly anchors of %d subscribers.", migrated)
+	}
+
+	return nil
+}
+
+func migrateFeedbackKeys(database *storm.DB) (databaseError error) {
+	var feedbackIdentifiers []FeedbackIdentifier
+	var subscribers []Subscriber
+	legacy := map[string][]int{}
+	migrated := 0
+
+	if databaseError = database.All(&feedbackIdentifiers); databaseError != nil {
+		return databaseError
+	}
+
+	for index, feedbackIdentifier := range feedbackIdentifiers {
+		if feedbackIdentifier.SubscriberUuid == "" {
+			legacy[feedbackIdentifier.DateString] = append(legacy[feedbackIdentifier.DateString], index)
+		}
+	}
+
+	if len(legacy) == 0 {
+		return nil
+	}
+
+	if databaseError = database.All(&subscribers); databaseError != nil {
+		return databaseError
+	}
+
+	for dateString, indices := range legacy {
+		owners := map[string]string{}
+
+		for _, subscriber := range subscribers {
+			owners[getLegacyKey(subscriber.Uuid, dateString)] = subscriber.Uuid
+		}
+
+		for _, index := range indices {
+			_, key := splitScopedKey(feedbackIdentifiers[index].Key)
+
+			if owner, found := owners[key]; found {
+				feedbackIdentifiers[index].SubscriberUuid = owner
+
+				if databaseError = database.Save(&feedbackIdentifiers[index]); databaseError != nil {
+					return databaseError
+				}
+				migrated++
+			}
+		}
+	}
+
+	if migrated > 0 {
+		log.Printf("Linked %d pending feedback keys to their subscribers.", migrated)
+	}
+
+	return nil
+}
+
+func getDatabasePath() string {
+	return getDataDirectory() + "app-mut.db"
 }
 
 func getDataDirectory() string {
@@ -72,89 +463,136 @@ func getDataDirectory() string {
 	}
 }
 
-func saveDailyMoods(database *storm.DB, dateString string) (databaseError error) {
+func saveDailyMoods(moods MoodRepository, dateString string, survey *Survey, invited int, paused int) (databaseError error) {
 	dailyMoods := new(DailyMoods)
 	dailyMoods.DateString = dateString
-	return database.Save(dailyMoods)
+	dailyMoods.SurveyId = survey.Id
+	dailyMoods.SurveyVersion = survey.Version
+	dailyMoods.Scale = DefaultScale
+	if scale, found := survey.MoodScale(); found {
+		dailyMoods.Scale = scale
+	}
+	dailyMoods.Counts = map[int]int{}
+	dailyMoods.Invited = invited
+	dailyMoods.Paused = paused
+	dailyMoods.FromResponses = true
+	return moods.Save(dailyMoods)
 }
 
-func updateDailyMoods(database *storm.DB, dateString string, mood string) (databaseError error) {
-	dailyMoods := new(DailyMoods)
-	databaseError = database.One("DateString", dateString, dailyMoods)
+func updateDailyMoods(moods MoodRepository, dateString string, mood string, weight float64) (databaseError error) {
+	dailyMoods, databaseError := moods.ByDate(dateString)
 
 	if databaseError != nil {
 		return databaseError
 	}
 
-	dailyMoods.AddMood(mood)
+	if databaseError = dailyMoods.AddMood(mood, weight); databaseError != nil {
+		return databaseError
+	}
 
-	return database.Save(dailyMoods)
+	return moods.Save(dailyMoods)
 }
 
-func getAllDailyMoods(database *storm.DB) (dailyMoods []DailyMoods, databaseError error) {
-	databaseError = database.All(&dailyMoods)
-	return dailyMoods, databaseError
+func getAllDailyMoods(moods MoodRepository) (dailyMoods []DailyMoods, databaseError error) {
+	return moods.All()
 }
 
-func saveSubscriber(database *storm.DB, subscription *Subscription) (subscriber Subscriber, databaseError error) {
+func saveSubscriber(repositories Repositories, subscription *Subscription) (subscriber Subscriber, databaseError error) {
 	uuid, _ := uuid.NewV4()
-	subscriber = Subscriber{uuid.String(), subscription.Email}
-	databaseError = database.Save(&subscriber)
+	now := repositories.Clock.Now()
+	subscriber = Subscriber{
+		Uuid:        uuid.String(),
+		Email:       subscription.Email,
+		Team:        subscription.Team,
+		Status:      SubscriberActive,
+		Name:        subscription.Name,
+		Locale:      subscription.Locale,
+		TimeZone:    subscription.TimeZone,
+		Channel:     subscription.Channel,
+		Attributes:  subscription.Attributes,
+		NoReminders: subscription.NoReminders,
+		Frequency:   subscription.Frequency,
+		Weekdays:    subscription.Weekdays,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	subscriber.anchorFrequency(now)
+
+	if databaseError = validateProfile(&subscriber); databaseError != nil {
+		return subscriber, databaseError
+	}
+
+	databaseError = repositories.Subscribers.Save(&subscriber)
 
 	return subscriber, databaseError
 }
 
-func getSubscriberByUuid(database *storm.DB, uuid string) (subscriber *Subscriber, databaseError error) {
-	subscriber = new(Subscriber)
-	databaseError = database.One("Uuid", uuid, subscriber)
-	return subscriber, databaseError
+func getSubscriberByUuid(subscribers SubscriberRepository, uuid string) (subscriber *Subscriber, databaseError error) {
+	return subscribers.ByUuid(uuid)
+}
+
+func getAllSubscribers(subscribers SubscriberRepository) ([]Subscriber, error) {
+	return subscribers.All()
 }
 
-func getAllSubscribers(database *storm.DB) (subscribers []Subscriber, databaseError error) {
-	databaseError = database.All(&subscribers)
-	return subscribers, databaseError
+func getDailyMoodsByDate(moods MoodRepository, dateString string) (dailyMoods *DailyMoods, databaseError error) {
+	return moods.ByDate(dateString)
 }
 
-func getFeedbackIdentifier(database *storm.DB, key string) (feedbackIdentifier *FeedbackIdentifier) {
-	feedbackIdentifier = new(FeedbackIdentifier)
-	databaseError := database.One("Key", key, feedbackIdentifier)
+func getFeedbackIdentifier(feedbackKeys FeedbackKeyRepository, key string) (feedbackIdentifier *FeedbackIdentifier) {
+	feedbackIdentifier, databaseError := feedbackKeys.ByKey(key)
 
 	if databaseError != nil {
 		return nil
 	}
 
-	database.Remove(feedbackIdentifier)
-
 	return feedbackIdentifier
 }
 
-func saveFeedbackIdentifierAndCreateMailTasks(subscribers []Subscriber, database *storm.DB) (tasks []MailTask, databaseError error) {
-	today := time.Now().Format("02-01-2006")
+func removeFeedbackIdentifier(feedbackKeys FeedbackKeyRepository, feedbackIdentifier *FeedbackIdentifier) error {
+	return feedbackKeys.Remove(feedbackIdentifier)
+}
+
+func saveFeedbackIdentifierAndCreateMailTasks(subscribers []Subscriber, database *storm.DB, repositories Repositories) (tasks []MailTask, databaseError error) {
+	today := repositories.Clock.Now().Format(dateLayout)
+	survey, databaseError := getActiveSurvey(database)
+
+	if databaseError != nil {
+		return nil, databaseError
+	}
+
+	recipients, paused := getSurveyRecipients(subscribers, repositories.Clock.Now())
 
-	if databaseError = saveDailyMoods(database, today); databaseError != nil {
+	if databaseError = saveDailyMoods(repositories.Moods, today, survey, len(recipients), paused); databaseError != nil {
 		return nil, databaseError
 	}
 
-	for _, subscriber := range subscribers {
-		key := createKey(subscriber.Uuid, today)
-		feedbackIdentifier := FeedbackIdentifier{key, today}
-		databaseError = database.Save(&feedbackIdentifier)
+	for _, subscriber := range recipients {
+		key := createKey(repositories.Organization)
+		feedbackIdentifier := FeedbackIdentifier{key, today, subscriber.Team, subscriber.surveyWeight(), subscriber.Uuid}
+		databaseError = repositories.FeedbackKeys.Save(&feedbackIdentifier)
 
 		if databaseError != nil {
 			return nil, databaseError
 		}
 
-		tasks = append(tasks, MailTask{subscriber.Email, key})
+		tasks = append(tasks, newMailTask(repositories.Organization, subscriber, key, survey.Subject, MailSurvey))
 	}
 
+	publishEvent(database, repositories.Clock, EventSurveySent, SurveySentEvent{today, survey.Id, survey.Version, len(recipients), paused})
+
 	return tasks, databaseError
 }
 
-func createKey(uuid string, dateString string) (key string) {
-	source := strings.Join([]string{uuid, dateString}, "-")
-	hashCreator := sha1.New()
-	hashCreator.Write([]byte(source))
-	key = hex.EncodeToString(hashCreator.Sum(nil))
+func createKey(organization *Organization) string {
+	key := make([]byte, 20)
+	_, _ = rand.Read(key)
 
-	return key
+	return scopeKey(getOrganizationId(organization), hex.EncodeToString(key))
+}
+
+func getLegacyKey(uuid string, dateString string) string {
+	hashCreator := sha1.New()
+	hashCreator.Write([]byte(strings.Join([]string{uuid, dateString}, "-")))
+	return hex.EncodeToString(hashCreator.Sum(nil))
 }
@@ -0,0 +1,23 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestFeedbackKeysAreNotDerivable(t *testing.T) {
+	organization := &Organization{Id: "acme"}
+	subscriber := Subscriber{Uuid: "listed-uuid", Email: "listed@example.com"}
+	keyPattern := regexp.MustCompile("^[0-9a-f]{40}$")
+	seen := map[string]bool{}
+
+	for run := 0; run < 20; run++ {
+		organizationId, key := splitScopedKey(createKey(organization))
+
+		expect(t, organizationId == organization.Id, "a feedback key must stay scoped to its organization, got %s", organizationId)
+		expect(t, keyPattern.MatchString(key), "a feedback key must be 40 hex characters, got %s", key)
+		expect(t, key != getLegacyKey(subscriber.Uuid, "01-06-2026"), "a feedback key must not be derivable from the listed uuid and the date")
+		expect(t, !seen[key], "feedback keys must not repeat, got %s twice", key)
+		seen[key] = true
+	}
+}
@@ -0,0 +1,160 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/labstack/echo"
+	"io/ioutil"
+	"net/http"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+type ProfileError struct {
+	Message string
+}
+
+const (
+	ChannelHtmlMail = "html-mail"
+	ChannelTextMail = "text-mail"
+)
+
+var localePattern = regexp.MustCompile(`^[a-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+var MaximumAttributes int = getEnvInt("MUT_MAX_SUBSCRIBER_ATTRIBUTES", 20)
+
+func (profileError *ProfileError) Error() string {
+	return profileError.Message
+}
+
+func validateProfile(subscriber *Subscriber) error {
+	if address, parseError := mail.ParseAddress(subscriber.Email); parseError != nil || address.Address != subscriber.Email {
+		return &ProfileError{"Email '" + subscriber.Email + "' is not a valid address!"}
+	}
+
+	if subscriber.Locale != "" && !localePattern.MatchString(subscriber.Locale) {
+		return &ProfileError{"Locale '" + subscriber.Locale + "' is not a valid language tag!"}
+	}
+
+	if _, locationError := time.LoadLocation(subscriber.TimeZone); locationError != nil {
+		return &ProfileError{"Time zone '" + subscriber.TimeZone + "' is unknown!"}
+	}
+
+	if subscriber.Channel != "" && subscriber.Channel != ChannelHtmlMail && subscriber.Channel != ChannelTextMail {
+		return &ProfileError{"Channel must be '" + ChannelHtmlMail + "' or '" + ChannelTextMail + "'!"}
+	}
+
+	if pauseError := validatePause(subscriber.PausedFrom, subscriber.PausedUntil); pauseError != nil {
+		return pauseError
+	}
+
+	if frequencyError := validateFrequency(subscriber.Frequency, subscriber.Weekdays, subscriber.FrequencyFrom); frequencyError != nil {
+		return frequencyError
+	}
+
+	if len(subscriber.Attributes) > MaximumAttributes {
+		return &ProfileError{"At most " + strconv.Itoa(MaximumAttributes) + " attributes are allowed!"}
+	}
+
+	return nil
+}
+
+func getLocation(timeZone string) *time.Location {
+	if location, locationError := time.LoadLocation(timeZone); locationError == nil && timeZone != "" {
+		return location
+	}
+	return time.Local
+}
+
+func mergePatch(target interface{}, patch interface{}) interface{} {
+	patchObject, isObject := patch.(map[string]interface{})
+
+	if !isObject {
+		return patch
+	}
+
+	targetObject, isObject := target.(map[string]interface{})
+
+	if !isObject {
+		targetObject = map[string]interface{}{}
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
+
+func applySubscriberPatch(subscriber *Subscriber, patch []byte, now time.Time) (patched *Subscriber, patchError error) {
+	var document interface{}
+	var changes interface{}
+
+	if patchError = json.Unmarshal(patch, &changes); patchError != nil {
+		return nil, &ProfileError{"Body is not a valid JSON merge patch!"}
+	}
+
+	if _, isObject := changes.(map[string]interface{}); !isObject {
+		return nil, &ProfileError{"Body must be a JSON object!"}
+	}
+
+	encoded, _ := json.Marshal(subscriber)
+	_ = json.Unmarshal(encoded, &document)
+	encoded, _ = json.Marshal(mergePatch(document, changes))
+
+	patched = new(Subscriber)
+
+	if patchError = json.Unmarshal(encoded, patched); patchError != nil {
+		return nil, &ProfileError{"Patch does not match the subscriber: " + patchError.Error()}
+	}
+
+	if patched.Uuid != subscriber.Uuid || patched.Status != subscriber.Status || !patched.CreatedAt.Equal(subscriber.CreatedAt) {
+		return nil, &ProfileError{"Fields 'uuid', 'status' and 'created-at' cannot be changed!"}
+	}
+
+	patched.UpdatedAt = now
+
+	if patched.Frequency != subscriber.Frequency && patched.FrequencyFrom == subscriber.FrequencyFrom {
+		patched.FrequencyFrom = ""
+	}
+	patched.anchorFrequency(patched.UpdatedAt)
+
+	return patched, validateProfile(patched)
+}
+
+func patchSubscriber(repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		uuid := context.Param("uuid")
+		subscriber, databaseError := getSubscriberByUuid(repositories.Subscribers, uuid)
+
+		if databaseError == ErrNotFound {
+			return context.String(http.StatusNotFound, "User with uuid '"+uuid+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		body, readError := ioutil.ReadAll(context.Request().Body())
+
+		if readError != nil {
+			return readError
+		}
+
+		patched, patchError := applySubscriberPatch(subscriber, body, repositories.Clock.Now())
+
+		if profileError, invalid := patchError.(*ProfileError); invalid {
+			return context.String(http.StatusBadRequest, profileError.Message)
+		}
+
+		if databaseError = repositories.Subscribers.Save(patched); databaseError == ErrDuplicate {
+			return context.String(http.StatusConflict, "User with email '"+patched.Email+"' already exists!")
+		} else if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, patched)
+		}
+	})
+}
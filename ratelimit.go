@@ -0,0 +1,194 @@
+package main
+
+import (
+	"github.com/labstack/echo"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type (
+	RateLimiter struct {
+		lock      sync.Mutex
+		rate      float64
+		burst     float64
+		buckets   map[string]*tokenBucket
+		lastSweep time.Time
+		clock     Clock
+	}
+
+	tokenBucket struct {
+		tokens  float64
+		updated time.Time
+	}
+
+	BanList struct {
+		lock      sync.Mutex
+		threshold int
+		window    time.Duration
+		duration  time.Duration
+		failures  map[string][]time.Time
+		bans      map[string]time.Time
+		clock     Clock
+	}
+)
+
+var RateLimitPerMinute float64 = getEnvFloat("MUT_RATE_LIMIT_PER_MINUTE", 30)
+var RateLimitBurst int = getEnvInt("MUT_RATE_LIMIT_BURST", 10)
+var BanThreshold int = getEnvInt("MUT_BAN_THRESHOLD", 10)
+var BanWindow time.Duration = time.Duration(getEnvInt("MUT_BAN_WINDOW_MINUTES", 10)) * time.Minute
+var BanDuration time.Duration = time.Duration(getEnvInt("MUT_BAN_MINUTES", 60)) * time.Minute
+var TrustProxy bool = getEnvString("MUT_TRUST_PROXY", "false") == "true"
+
+func newRateLimiter(perMinute float64, burst int, clock Clock) *RateLimiter {
+	return &RateLimiter{rate: perMinute / 60, burst: float64(burst), buckets: map[string]*tokenBucket{}, clock: clock}
+}
+
+func (limiter *RateLimiter) Take(key string) (allowed bool, remaining int, wait time.Duration) {
+	limiter.lock.Lock()
+	defer limiter.lock.Unlock()
+
+	now := limiter.clock.Now()
+	limiter.sweep(now)
+
+	bucket, found := limiter.buckets[key]
+	if !found {
+		bucket = &tokenBucket{limiter.burst, now}
+		limiter.buckets[key] = bucket
+	}
+
+	bucket.tokens = math.Min(limiter.burst, bucket.tokens+now.Sub(bucket.updated).Seconds()*limiter.rate)
+	bucket.updated = now
+
+	if bucket.tokens >= 1 {
+		bucket.tokens--
+		return true, int(bucket.tokens), 0
+	}
+
+	return false, 0, time.Duration((1 - bucket.tokens) / limiter.rate * float64(time.Second))
+}
+
+func (limiter *RateLimiter) sweep(now time.Time) {
+	if now.Sub(limiter.lastSweep) < time.Minute {
+		return
+	}
+
+	limiter.lastSweep = now
+	full := time.Duration(limiter.burst / limiter.rate * float64(time.Second))
+
+	for key, bucket := range limiter.buckets {
+		if now.Sub(bucket.updated) > full {
+			delete(limiter.buckets, key)
+		}
+	}
+}
+
+func newBanList(threshold int, window time.Duration, duration time.Duration, clock Clock) *BanList {
+	return &BanList{threshold: threshold, window: window, duration: duration, failures: map[string][]time.Time{}, bans: map[string]time.Time{}, clock: clock}
+}
+
+func (banList *BanList) BannedFor(ip string) time.Duration {
+	banList.lock.Lock()
+	defer banList.lock.Unlock()
+
+	until, banned := banList.bans[ip]
+	now := banList.clock.Now()
+
+	if !banned {
+		return 0
+	}
+
+	if !now.Before(until) {
+		delete(banList.bans, ip)
+		return 0
+	}
+
+	return until.Sub(now)
+}
+
+func (banList *BanList) RecordFailure(ip string) {
+	banList.lock.Lock()
+	defer banList.lock.Unlock()
+
+	now := banList.clock.Now()
+	recent := []time.Time{}
+
+	for _, failure := range banList.failures[ip] {
+		if now.Sub(failure) < banList.window {
+			recent = append(recent, failure)
+		}
+	}
+
+	recent = append(recent, now)
+
+	if len(recent) >= banList.threshold {
+		banList.bans[ip] = now.Add(banList.duration)
+		delete(banList.failures, ip)
+	} else {
+		banList.failures[ip] = recent
+	}
+}
+
+func getClientIp(context echo.Context) string {
+	if TrustProxy {
+		if forwarded := context.Request().Header().Get("X-Forwarded-For"); forwarded != "" {
+			return strings.TrimSpace(strings.Split(forwarded, ",")[0])
+		}
+	}
+
+	address := context.Request().RemoteAddress()
+
+	if host, _, splitError := net.SplitHostPort(address); splitError == nil {
+		return host
+	}
+	return address
+}
+
+func retryAfterSeconds(wait time.Duration) string {
+	return strconv.Itoa(int(math.Ceil(wait.Seconds())))
+}
+
+func rateLimit(limiter *RateLimiter, route string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(context echo.Context) error {
+			allowed, remaining, wait := limiter.Take(getClientIp(context) + " " + route)
+			header := context.Response().Header()
+
+			header.Set("X-RateLimit-Limit", strconv.Itoa(int(limiter.burst)))
+			header.Set("X-RateLimit-Remaining", strconv.Itoa(remaining))
+
+			if !allowed {
+				header.Set("X-RateLimit-Reset", strconv.FormatInt(limiter.clock.Now().Add(wait).Unix(), 10))
+				header.Set("Retry-After", retryAfterSeconds(wait))
+				return context.String(http.StatusTooManyRequests, translate(getRequestLocale(context), "rate.limited"))
+			}
+
+			return next(context)
+		}
+	}
+}
+
+func banOnNotFound(banList *BanList) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(context echo.Context) error {
+			ip := getClientIp(context)
+
+			if wait := banList.BannedFor(ip); wait > 0 {
+				context.Response().Header().Set("Retry-After", retryAfterSeconds(wait))
+				return context.String(http.StatusForbidden, translate(getRequestLocale(context), "rate.banned"))
+			}
+
+			handlerError := next(context)
+
+			if context.Response().Status() == http.StatusNotFound {
+				banList.RecordFailure(ip)
+			}
+
+			return handlerError
+		}
+	}
+}
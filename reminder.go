@@ -0,0 +1,116 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+)
+
+var ReminderDelayHours int = getEnvInt("MUT_REMINDER_DELAY_HOURS", 0)
+var ReminderLimit int = getEnvInt("MUT_REMINDER_LIMIT", 1)
+var ReminderSchedule string = getEnvString("MUT_REMINDER_SCHEDULE", "0 */15 * * * *")
+var ReminderSubject string = os.Getenv("MUT_REMINDER_SUBJECT")
+var ReminderBatchSize int = getEnvInt("MUT_REMINDER_BATCH_SIZE", 100)
+
+func sendReminders(repositories Repositories) func() {
+	return func() {
+		tasks, reminderError := createReminderTasks(repositories)
+
+		if reminderError != nil {
+			log.Printf("%s", reminderError)
+		}
+
+		if len(tasks) > 0 {
+			log.Printf("Sending %d reminders.", len(tasks))
+			sendMails(repositories, tasks)
+		}
+	}
+}
+
+func createReminderTasks(repositories Repositories) (tasks []MailTask, databaseError error) {
+	latest, databaseError := repositories.Moods.Between("", lastKey, 1, true)
+
+	if databaseError != nil || len(latest) == 0 {
+		return nil, databaseError
+	}
+
+	page := Page{Limit: ReminderBatchSize}
+
+	for {
+		subscribers, next, databaseError := repositories.Subscribers.Page(SubscriberFilter{Status: SubscriberActive}, page)
+
+		if databaseError != nil {
+			return tasks, databaseError
+		}
+
+		for _, subscriber := range subscribers {
+			task, due, databaseError := getReminderTask(repositories, subscriber, latest[0].DateString)
+
+			if databaseError != nil {
+				return tasks, databaseError
+			} else if due {
+				tasks = append(tasks, task)
+			}
+		}
+
+		if next == "" {
+			return tasks, nil
+		}
+		page.Cursor = next
+	}
+}
+
+func getReminderTask(repositories Repositories, subscriber Subscriber, dateString string) (task MailTask, due bool, databaseError error) {
+	if subscriber.NoReminders {
+		return task, false, nil
+	}
+
+	feedbackIdentifiers, databaseError := repositories.FeedbackKeys.BySubscriber(subscriber.Uuid)
+
+	if databaseError != nil {
+		return task, false, databaseError
+	}
+
+	key := ""
+
+	for _, feedbackIdentifier := range feedbackIdentifiers {
+		if feedbackIdentifier.DateString == dateString {
+			key = feedbackIdentifier.Key
+		}
+	}
+
+	if key == "" {
+		return task, false, nil
+	}
+
+	deliveries, databaseError := repositories.MailTasks.BySubscriber(subscriber.Uuid)
+
+	if databaseError != nil {
+		return task, false, databaseError
+	}
+
+	var surveySentAt time.Time
+	reminders := 0
+
+	for _, delivery := range deliveries {
+		if delivery.Key != key {
+			continue
+		}
+
+		if delivery.Kind == MailReminder {
+			reminders++
+		} else if delivery.Status == DeliverySent && (surveySentAt.IsZero() || delivery.SentAt.Before(surveySentAt)) {
+			surveySentAt = delivery.SentAt
+		}
+	}
+
+	if surveySentAt.IsZero() || reminders >= ReminderLimit {
+		return task, false, nil
+	}
+
+	if repositories.Clock.Now().Before(surveySentAt.Add(time.Duration(ReminderDelayHours*(reminders+1)) * time.Hour)) {
+		return task, false, nil
+	}
+
+	return newMailTask(repositories.Organization, subscriber, key, ReminderSubject, MailReminder), true, nil
+}
@@ -0,0 +1,286 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+type (
+	MailReply struct {
+		Recipient string
+		InReplyTo string
+		Text      string
+	}
+
+	ReplyError struct {
+		Message string
+	}
+)
+
+const mailReplyContextKey = "mail-reply"
+
+var ReplyAddress string = os.Getenv("MUT_REPLY_ADDRESS")
+
+func (replyError *ReplyError) Error() string {
+	return replyError.Message
+}
+
+func getReplyAddress(address string, key string) string {
+	at := strings.LastIndex(address, "@")
+
+	if address == "" || at < 0 {
+		return ""
+	}
+	return address[:at] + "+" + key + address[at:]
+}
+
+func getMessageId(address string, key string, kind string, now time.Time) string {
+	at := strings.LastIndex(address, "@")
+
+	if address == "" || at < 0 {
+		return ""
+	}
+	return "<" + key + "." + kind + "." + strconv.FormatInt(now.UnixNano(), 36) + address[at:] + ">"
+}
+
+func (reply MailReply) keyCandidates() (keys []string) {
+	if address, parseError := mail.ParseAddress(reply.Recipient); parseError == nil {
+		local := address.Address[:strings.LastIndex(address.Address, "@")]
+
+		if plus := strings.Index(local, "+"); plus >= 0 {
+			keys = append(keys, local[plus+1:])
+		}
+	}
+
+	for _, messageId := range strings.Fields(reply.InReplyTo) {
+		messageId = strings.Trim(messageId, "<>")
+
+		if end := strings.IndexAny(messageId, ".@"); end > 0 {
+			keys = append(keys, messageId[:end])
+		}
+	}
+
+	return keys
+}
+
+func (reply MailReply) resolve(feedbackKeys FeedbackKeyRepository) *FeedbackIdentifier {
+	for _, key := range reply.keyCandidates() {
+		if feedbackIdentifier := getFeedbackIdentifier(feedbackKeys, key); feedbackIdentifier != nil {
+			return feedbackIdentifier
+		}
+	}
+	return nil
+}
+
+func getFirstLine(text string) string {
+	for _, line := range strings.Split(text, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			return line
+		}
+	}
+	return ""
+}
+
+func parseReplyMood(scale Scale, text string) (string, bool) {
+	line := getFirstLine(text)
+	digits := strings.IndexFunc(line, func(character rune) bool { return !unicode.IsDigit(character) })
+
+	if digits < 0 {
+		digits = len(line)
+	}
+
+	if digits > 0 {
+		if value, parseError := scale.ParseValue(line[:digits]); parseError == nil {
+			return strconv.Itoa(value), true
+		}
+		return "", false
+	}
+
+	line = strings.Replace(line, "\ufe0f", "", -1)
+
+	for _, point := range scale.Points {
+		if emoji := strings.Replace(point.Emoji, "\ufe0f", "", -1); emoji != "" && strings.HasPrefix(line, emoji) {
+			return strconv.Itoa(point.Value), true
+		}
+	}
+
+	return "", false
+}
+
+func recordReply(database *storm.DB, repositories Repositories, reply MailReply) error {
+	feedbackIdentifier := reply.resolve(repositories.FeedbackKeys)
+
+	if feedbackIdentifier == nil {
+		return &ReplyError{"Reply does not belong to an open survey!"}
+	}
+
+	dailyMoods, databaseError := getDailyMoodsByDate(repositories.Moods, feedbackIdentifier.DateString)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	mood, found := parseReplyMood(dailyMoods.Scale, reply.Text)
+
+	if !found {
+		return &ReplyError{"First line of the reply is not a mood!"}
+	}
+
+	if databaseError = recordSurveyAnswers(database, repositories, feedbackIdentifier, Answers{MoodQuestionId: {mood}}, ChannelReply); databaseError == ErrNotFound {
+		return &ReplyError{"Reply does not belong to an open survey!"}
+	}
+	return databaseError
+}
+
+func parseMailReply(reader io.Reader) (reply MailReply, parseError error) {
+	message, parseError := mail.ReadMessage(reader)
+
+	if parseError != nil {
+		return reply, &ReplyError{"Body is not a mail message!"}
+	}
+
+	reply.Recipient = message.Header.Get("To")
+	reply.InReplyTo = message.Header.Get("In-Reply-To") + " " + message.Header.Get("References")
+
+	if addresses, listError := message.Header.AddressList("To"); listError == nil {
+		for _, address := range addresses {
+			if strings.Contains(address.Address, "+") {
+				reply.Recipient = address.Address
+			}
+		}
+	}
+
+	reply.Text, parseError = getPlainBody(message.Header.Get("Content-Type"), message.Header.Get("Content-Transfer-Encoding"), message.Body)
+	return reply, parseError
+}
+
+func getPlainBody(contentType string, encoding string, body io.Reader) (string, error) {
+	mediaType, parameters, parseError := mime.ParseMediaType(contentType)
+
+	if contentType == "" {
+		mediaType, parseError = "text/plain", nil
+	}
+
+	if parseError != nil {
+		return "", &ReplyError{"Reply has an invalid content type!"}
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+		parts := multipart.NewReader(body, parameters["boundary"])
+
+		for {
+			part, partError := parts.NextPart()
+
+			if partError == io.EOF {
+				break
+			} else if partError != nil {
+				return "", &ReplyError{"Reply is not a valid multipart message!"}
+			}
+
+			if text, textError := getPlainBody(part.Header.Get("Content-Type"), part.Header.Get("Content-Transfer-Encoding"), part); textError == nil && text != "" {
+				return text, nil
+			}
+		}
+		return "", &ReplyError{"Reply has no plain text part!"}
+	}
+
+	if mediaType != "text/plain" {
+		return "", nil
+	}
+
+	switch strings.ToLower(encoding) {
+	case "quoted-printable":
+		body = quotedprintable.NewReader(body)
+	case "base64":
+		body = base64.NewDecoder(base64.StdEncoding, body)
+	}
+
+	text, readError := ioutil.ReadAll(body)
+	return string(text), readError
+}
+
+func getMailgunHeader(context echo.Context, name string) string {
+	if value := context.FormValue(name); value != "" {
+		return value
+	}
+
+	var headers [][]string
+	_ = json.Unmarshal([]byte(context.FormValue("message-headers")), &headers)
+
+	for _, header := range headers {
+		if len(header) == 2 && strings.EqualFold(header[0], name) {
+			return header[1]
+		}
+	}
+	return ""
+}
+
+func respondToReply(context echo.Context, replyError error) error {
+	if replyError == nil {
+		return context.String(http.StatusCreated, translate(DefaultLocale, "form.thanks"))
+	} else if invalid, isReplyError := replyError.(*ReplyError); isReplyError {
+		return context.String(http.StatusNotAcceptable, invalid.Message)
+	} else if invalid, isAnswerError := replyError.(*AnswerError); isAnswerError {
+		return context.String(http.StatusNotAcceptable, invalid.Message)
+	}
+	return replyError
+}
+
+func getMailgunReply(context echo.Context) MailReply {
+	if parsed, found := context.Get(mailReplyContextKey).(MailReply); found {
+		return parsed
+	}
+
+	reply := MailReply{
+		Recipient: context.FormValue("recipient"),
+		InReplyTo: getMailgunHeader(context, "In-Reply-To") + " " + getMailgunHeader(context, "References"),
+		Text:      context.FormValue("stripped-text"),
+	}
+
+	if reply.Text == "" {
+		reply.Text = context.FormValue("body-plain")
+	}
+
+	context.Set(mailReplyContextKey, reply)
+	return reply
+}
+
+func postMailgunReply(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		if MailgunSigningKey == "" {
+			return context.String(http.StatusForbidden, "Mailgun events are disabled!")
+		}
+
+		if !verifyMailgunSignature(context.FormValue("timestamp"), context.FormValue("token"), context.FormValue("signature"), repositories.Clock.Now()) {
+			return context.String(http.StatusUnauthorized, "Invalid Mailgun signature!")
+		}
+
+		return respondToReply(context, recordReply(database, repositories, getMailgunReply(context)))
+	})
+}
+
+func postRawReply(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		reply, parseError := parseMailReply(context.Request().Body())
+
+		if parseError != nil {
+			return respondToReply(context, parseError)
+		}
+
+		return respondToReply(context, recordReply(database, repositories, reply))
+	})
+}
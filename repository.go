@@ -0,0 +1,53 @@
+package main
+
+import (
+	"github.com/asdine/storm"
+	"time"
+)
+
+type (
+	SubscriberRepository interface {
+		Save(subscriber *Subscriber) error
+		ByUuid(uuid string) (*Subscriber, error)
+		ByEmail(email string) (*Subscriber, error)
+		All() ([]Subscriber, error)
+		Page(filter SubscriberFilter, page Page) (subscribers []Subscriber, next string, databaseError error)
+		Count(filter SubscriberFilter) (int, error)
+		Remove(subscriber *Subscriber) error
+	}
+
+	FeedbackKeyRepository interface {
+		Save(feedbackIdentifier *FeedbackIdentifier) error
+		ByKey(key string) (*FeedbackIdentifier, error)
+		Before(cutoff time.Time, skip int, limit int) ([]FeedbackIdentifier, error)
+		BySubscriber(uuid string) ([]FeedbackIdentifier, error)
+		Remove(feedbackIdentifier *FeedbackIdentifier) error
+	}
+
+	MoodRepository interface {
+		Save(dailyMoods *DailyMoods) error
+		ByDate(dateString string) (*DailyMoods, error)
+		All() ([]DailyMoods, error)
+		Between(fromDay string, toDay string, limit int, reverse bool) ([]DailyMoods, error)
+		Count(fromDay string, toDay string) (int, error)
+	}
+
+	MailTaskRepository interface {
+		Save(delivery *MailDelivery) error
+		BySubscriber(uuid string) ([]MailDelivery, error)
+		Remove(delivery *MailDelivery) error
+	}
+
+	Repositories struct {
+		Subscribers  SubscriberRepository
+		FeedbackKeys FeedbackKeyRepository
+		Moods        MoodRepository
+		MailTasks    MailTaskRepository
+		Organization *Organization
+		Clock        Clock
+		Mailer       Mailer
+	}
+)
+
+var ErrNotFound = storm.ErrNotFound
+var ErrDuplicate = storm.ErrAlreadyExists
@@ -0,0 +1,216 @@
+package main
+
+import (
+	"github.com/asdine/storm"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func expect(t *testing.T, condition bool, format string, arguments ...interface{}) {
+	t.Helper()
+
+	if !condition {
+		t.Errorf(format, arguments...)
+	}
+}
+
+func openConformanceDatabase(t *testing.T) *storm.DB {
+	directory, directoryError := ioutil.TempDir("", "mutservice-conformance-")
+
+	if directoryError != nil {
+		t.Fatal(directoryError)
+	}
+
+	t.Cleanup(func() { os.RemoveAll(directory) })
+
+	database, databaseError := storm.Open(filepath.Join(directory, "conformance.db"))
+
+	if databaseError != nil {
+		t.Fatal(databaseError)
+	}
+
+	t.Cleanup(func() { database.Close() })
+
+	_ = database.Init(&Subscriber{})
+	_ = database.Init(&FeedbackIdentifier{})
+	_ = database.Init(&DailyMoods{})
+	_ = database.Init(&MailDelivery{})
+
+	return database
+}
+
+func TestRepositoryConformance(t *testing.T) {
+	t.Run("memory", func(t *testing.T) {
+		checkRepositoryConformance(t, newMemoryRepositories())
+	})
+
+	t.Run("storm", func(t *testing.T) {
+		checkRepositoryConformance(t, newStormRepositories(openConformanceDatabase(t)))
+	})
+}
+
+func checkRepositoryConformance(t *testing.T, repositories Repositories) {
+	checkSubscriberRepository(t, repositories.Subscribers)
+	checkFeedbackKeyRepository(t, repositories.FeedbackKeys)
+	checkMoodRepository(t, repositories.Moods)
+	checkMailTaskRepository(t, repositories.MailTasks)
+}
+
+func checkSubscriberRepository(t *testing.T, subscribers SubscriberRepository) {
+	first := Subscriber{Uuid: "conformance-1", Email: "first@example.com"}
+	second := Subscriber{Uuid: "conformance-2", Email: "first@example.com"}
+
+	expect(t, subscribers.Save(&first) == nil, "subscribers: saving a new subscriber failed")
+	expect(t, subscribers.Save(&second) == ErrDuplicate, "subscribers: saving a duplicate email must fail with ErrDuplicate")
+
+	found, databaseError := subscribers.ByUuid(first.Uuid)
+	expect(t, databaseError == nil && found.Email == first.Email, "subscribers: ByUuid must return the saved subscriber")
+
+	found, databaseError = subscribers.ByEmail(first.Email)
+	expect(t, databaseError == nil && found.Uuid == first.Uuid, "subscribers: ByEmail must return the saved subscriber")
+
+	_, databaseError = subscribers.ByEmail("missing@example.com")
+	expect(t, databaseError == ErrNotFound, "subscribers: ByEmail of an unknown address must fail with ErrNotFound")
+
+	first.Team = "changed"
+	expect(t, subscribers.Save(&first) == nil, "subscribers: updating a subscriber failed")
+
+	found, databaseError = subscribers.ByUuid(first.Uuid)
+	expect(t, databaseError == nil && found.Team == "changed", "subscribers: updates must be persisted")
+
+	all, databaseError := subscribers.All()
+	expect(t, databaseError == nil && len(all) == 1, "subscribers: All must return exactly the saved subscribers, got %d", len(all))
+
+	checkSubscriberPages(t, subscribers)
+
+	expect(t, subscribers.Remove(&first) == nil, "subscribers: removing a subscriber failed")
+
+	_, databaseError = subscribers.ByUuid(first.Uuid)
+	expect(t, databaseError == ErrNotFound, "subscribers: ByUuid of a removed subscriber must fail with ErrNotFound")
+	expect(t, subscribers.Remove(&first) == ErrNotFound, "subscribers: removing a missing subscriber must fail with ErrNotFound")
+}
+
+func checkSubscriberPages(t *testing.T, subscribers SubscriberRepository) {
+	paged := []Subscriber{
+		{Uuid: "page-1", Email: "a@example.com", Status: SubscriberActive},
+		{Uuid: "page-2", Email: "b@example.com", Status: "paused"},
+		{Uuid: "page-3", Email: "c@example.com", Status: SubscriberActive},
+		{Uuid: "page-4", Email: "d@example.org", Status: SubscriberActive},
+	}
+
+	for index := range paged {
+		expect(t, subscribers.Save(&paged[index]) == nil, "subscribers: saving %s failed", paged[index].Email)
+	}
+
+	page, next, databaseError := subscribers.Page(SubscriberFilter{EmailPrefix: "a@", Status: ""}, Page{Limit: 10})
+	expect(t, databaseError == nil && len(page) == 1 && next == "", "subscribers: Page must filter by email prefix, got %d", len(page))
+
+	filter := SubscriberFilter{Status: SubscriberActive}
+	page, next, databaseError = subscribers.Page(filter, Page{Limit: 2})
+	expect(t, databaseError == nil && len(page) == 2 && page[0].Email == "a@example.com" && page[1].Email == "c@example.com" && next == "c@example.com", "subscribers: the first page must skip other statuses and return a cursor")
+
+	page, next, databaseError = subscribers.Page(filter, Page{Cursor: next, Limit: 2})
+	expect(t, databaseError == nil && len(page) == 1 && page[0].Email == "d@example.org" && next == "", "subscribers: the last page must continue after the cursor")
+
+	page, _, databaseError = subscribers.Page(filter, Page{Limit: 1, Reverse: true})
+	expect(t, databaseError == nil && len(page) == 1 && page[0].Email == "d@example.org", "subscribers: reverse pages must start with the last email")
+
+	count, databaseError := subscribers.Count(filter)
+	expect(t, databaseError == nil && count == 3, "subscribers: Count must honour the filter, got %d", count)
+
+	for index := range paged {
+		expect(t, subscribers.Remove(&paged[index]) == nil, "subscribers: removing %s failed", paged[index].Email)
+	}
+}
+
+func checkFeedbackKeyRepository(t *testing.T, feedbackKeys FeedbackKeyRepository) {
+	for _, key := range []string{"key-a", "key-b", "key-c"} {
+		expect(t, feedbackKeys.Save(&FeedbackIdentifier{key, "01-01-2016", "", 1, "uuid-a"}) == nil, "feedback keys: saving %s failed", key)
+	}
+	expect(t, feedbackKeys.Save(&FeedbackIdentifier{"key-d", "02-01-2016", "", 1, "uuid-b"}) == nil, "feedback keys: saving key-d failed")
+
+	found, databaseError := feedbackKeys.ByKey("key-b")
+	expect(t, databaseError == nil && found.DateString == "01-01-2016", "feedback keys: ByKey must return the saved key")
+
+	page, databaseError := feedbackKeys.Before(time.Date(2016, time.January, 2, 0, 0, 0, 0, time.UTC), 0, 10)
+	expect(t, databaseError == nil && len(page) == 3, "feedback keys: Before must return all keys older than the cutoff, got %d", len(page))
+
+	page, databaseError = feedbackKeys.Before(time.Date(2016, time.January, 2, 0, 0, 0, 0, time.UTC), 1, 1)
+	expect(t, databaseError == nil && len(page) == 1, "feedback keys: Before must honour skip and limit, got %d", len(page))
+
+	page, databaseError = feedbackKeys.Before(time.Date(2016, time.January, 1, 0, 0, 0, 0, time.UTC), 0, 10)
+	expect(t, databaseError == nil && len(page) == 0, "feedback keys: Before an early cutoff must return nothing")
+
+	page, databaseError = feedbackKeys.Before(time.Date(2016, time.February, 1, 0, 0, 0, 0, time.UTC), 0, 10)
+	expect(t, databaseError == nil && len(page) == 4, "feedback keys: Before must compare dates, not date strings, got %d", len(page))
+
+	page, databaseError = feedbackKeys.BySubscriber("uuid-b")
+	expect(t, databaseError == nil && len(page) == 1 && page[0].Key == "key-d", "feedback keys: BySubscriber must return the keys of the subscriber, got %v", page)
+
+	page, databaseError = feedbackKeys.BySubscriber("uuid-c")
+	expect(t, databaseError == nil && len(page) == 0, "feedback keys: BySubscriber of a subscriber without keys must return nothing")
+
+	expect(t, feedbackKeys.Remove(found) == nil, "feedback keys: removing a key failed")
+
+	_, databaseError = feedbackKeys.ByKey("key-b")
+	expect(t, databaseError == ErrNotFound, "feedback keys: ByKey of a removed key must fail with ErrNotFound")
+}
+
+func checkMoodRepository(t *testing.T, moods MoodRepository) {
+	_, databaseError := moods.ByDate("01-01-2016")
+	expect(t, databaseError == ErrNotFound, "moods: ByDate of a missing day must fail with ErrNotFound")
+
+	dailyMoods := DailyMoods{DateString: "01-01-2016", Scale: DefaultScale, Counts: map[int]int{}, Invited: 3}
+	expect(t, moods.Save(&dailyMoods) == nil, "moods: saving a day failed")
+
+	found, databaseError := moods.ByDate(dailyMoods.DateString)
+	expect(t, databaseError == nil && found.Invited == 3, "moods: ByDate must return the saved day")
+
+	if databaseError == nil {
+		expect(t, found.AddMood("2", 1) == nil, "moods: adding a mood on the default scale failed")
+		expect(t, moods.Save(found) == nil, "moods: updating a day failed")
+	}
+
+	found, databaseError = moods.ByDate(dailyMoods.DateString)
+	expect(t, databaseError == nil && found.Total() == 1 && found.Counts[2] == 1, "moods: counts must be persisted")
+
+	all, databaseError := moods.All()
+	expect(t, databaseError == nil && len(all) == 1, "moods: All must return exactly the saved days, got %d", len(all))
+
+	for _, dateString := range []string{"31-12-2015", "15-01-2016", "01-02-2016"} {
+		expect(t, moods.Save(&DailyMoods{DateString: dateString, Scale: DefaultScale, Counts: map[int]int{}}) == nil, "moods: saving %s failed", dateString)
+	}
+
+	between, databaseError := moods.Between("2016-01-01", "2016-01-31", 0, false)
+	expect(t, databaseError == nil && len(between) == 2 && between[0].DateString == "01-01-2016", "moods: Between must return the days of the range in date order")
+
+	between, databaseError = moods.Between("", lastKey, 1, true)
+	expect(t, databaseError == nil && len(between) == 1 && between[0].DateString == "01-02-2016", "moods: Between must honour limit and reverse")
+
+	count, databaseError := moods.Count("2016-01-01", lastKey)
+	expect(t, databaseError == nil && count == 3, "moods: Count must count the days of the range, got %d", count)
+}
+
+func checkMailTaskRepository(t *testing.T, mailTasks MailTaskRepository) {
+	deliveries := []MailDelivery{
+		{Id: "delivery-1", SubscriberUuid: "conformance-1", Status: DeliverySent, SentAt: time.Now()},
+		{Id: "delivery-2", SubscriberUuid: "conformance-1", Status: DeliveryFailed, SentAt: time.Now()},
+		{Id: "delivery-3", SubscriberUuid: "conformance-2", Status: DeliverySent, SentAt: time.Now()},
+	}
+
+	for index := range deliveries {
+		expect(t, mailTasks.Save(&deliveries[index]) == nil, "mail tasks: saving %s failed", deliveries[index].Id)
+	}
+
+	found, databaseError := mailTasks.BySubscriber("conformance-1")
+	expect(t, databaseError == nil && len(found) == 2, "mail tasks: BySubscriber must return all deliveries of the subscriber, got %d", len(found))
+
+	expect(t, mailTasks.Remove(&deliveries[2]) == nil, "mail tasks: removing a delivery failed")
+
+	found, databaseError = mailTasks.BySubscriber("conformance-2")
+	expect(t, databaseError == nil && len(found) == 0, "mail tasks: BySubscriber must not return removed deliveries")
+	expect(t, mailTasks.Remove(&deliveries[2]) == ErrNotFound, "mail tasks: removing a missing delivery must fail with ErrNotFound")
+}
@@ -0,0 +1,353 @@
+package main
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+type (
+	memorySubscriberRepository struct {
+		lock        sync.RWMutex
+		subscribers map[string]Subscriber
+	}
+
+	memoryFeedbackKeyRepository struct {
+		lock                sync.RWMutex
+		feedbackIdentifiers map[string]FeedbackIdentifier
+	}
+
+	memoryMoodRepository struct {
+		lock    sync.RWMutex
+		history map[string]DailyMoods
+	}
+
+	memoryMailTaskRepository struct {
+		lock       sync.RWMutex
+		deliveries map[string]MailDelivery
+	}
+)
+
+func newMemoryRepositories() Repositories {
+	return Repositories{
+		Subscribers:  &memorySubscriberRepository{subscribers: map[string]Subscriber{}},
+		FeedbackKeys: &memoryFeedbackKeyRepository{feedbackIdentifiers: map[string]FeedbackIdentifier{}},
+		Moods:        &memoryMoodRepository{history: map[string]DailyMoods{}},
+		MailTasks:    &memoryMailTaskRepository{deliveries: map[string]MailDelivery{}},
+		Clock:        systemClock{},
+		Mailer:       mailgunMailer{},
+	}
+}
+
+func (repository *memorySubscriberRepository) Save(subscriber *Subscriber) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	for uuid, existing := range repository.subscribers {
+		if uuid != subscriber.Uuid && existing.Email == subscriber.Email {
+			return ErrDuplicate
+		}
+	}
+
+	repository.subscribers[subscriber.Uuid] = copySubscriber(*subscriber)
+	return nil
+}
+
+func (repository *memorySubscriberRepository) ByUuid(uuid string) (*Subscriber, error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	if subscriber, found := repository.subscribers[uuid]; found {
+		subscriber = copySubscriber(subscriber)
+		return &subscriber, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (repository *memorySubscriberRepository) ByEmail(email string) (*Subscriber, error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, subscriber := range repository.subscribers {
+		if subscriber.Email == email {
+			subscriber = copySubscriber(subscriber)
+			return &subscriber, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+func (repository *memorySubscriberRepository) All() (subscribers []Subscriber, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, subscriber := range repository.subscribers {
+		subscribers = append(subscribers, subscriber)
+	}
+
+	sort.Slice(subscribers, func(i, j int) bool {
+		return subscribers[i].Uuid < subscribers[j].Uuid
+	})
+
+	return subscribers, nil
+}
+
+func (repository *memorySubscriberRepository) Page(filter SubscriberFilter, page Page) (subscribers []Subscriber, next string, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, subscriber := range repository.subscribers {
+		if filter.matches(subscriber) && page.isAfter(subscriber.Email) {
+			subscribers = append(subscribers, subscriber)
+		}
+	}
+
+	sort.Slice(subscribers, func(i, j int) bool {
+		return (subscribers[i].Email < subscribers[j].Email) != page.Reverse
+	})
+
+	if len(subscribers) > page.Limit {
+		subscribers = subscribers[:page.Limit]
+		next = subscribers[page.Limit-1].Email
+	}
+
+	return subscribers, next, nil
+}
+
+func (repository *memorySubscriberRepository) Count(filter SubscriberFilter) (count int, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, subscriber := range repository.subscribers {
+		if filter.matches(subscriber) {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func copySubscriber(subscriber Subscriber) Subscriber {
+	if subscriber.Attributes != nil {
+		attributes := map[string]string{}
+		for name, value := range subscriber.Attributes {
+			attributes[name] = value
+		}
+		subscriber.Attributes = attributes
+	}
+	return subscriber
+}
+
+func (repository *memorySubscriberRepository) Remove(subscriber *Subscriber) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	if _, found := repository.subscribers[subscriber.Uuid]; !found {
+		return ErrNotFound
+	}
+
+	delete(repository.subscribers, subscriber.Uuid)
+	return nil
+}
+
+func (repository *memoryFeedbackKeyRepository) Save(feedbackIdentifier *FeedbackIdentifier) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	repository.feedbackIdentifiers[feedbackIdentifier.Key] = *feedbackIdentifier
+	return nil
+}
+
+func (repository *memoryFeedbackKeyRepository) ByKey(key string) (*FeedbackIdentifier, error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	if feedbackIdentifier, found := repository.feedbackIdentifiers[key]; found {
+		return &feedbackIdentifier, nil
+	}
+	return nil, ErrNotFound
+}
+
+func (repository *memoryFeedbackKeyRepository) Before(cutoff time.Time, skip int, limit int) (feedbackIdentifiers []FeedbackIdentifier, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, feedbackIdentifier := range repository.feedbackIdentifiers {
+		if feedbackIdentifier.Date().Before(cutoff) {
+			feedbackIdentifiers = append(feedbackIdentifiers, feedbackIdentifier)
+		}
+	}
+
+	sort.Slice(feedbackIdentifiers, func(i, j int) bool {
+		return feedbackIdentifiers[i].Key < feedbackIdentifiers[j].Key
+	})
+
+	if skip >= len(feedbackIdentifiers) {
+		return nil, nil
+	}
+
+	feedbackIdentifiers = feedbackIdentifiers[skip:]
+
+	if limit > 0 && limit < len(feedbackIdentifiers) {
+		feedbackIdentifiers = feedbackIdentifiers[:limit]
+	}
+
+	return feedbackIdentifiers, nil
+}
+
+func (repository *memoryFeedbackKeyRepository) BySubscriber(uuid string) (feedbackIdentifiers []FeedbackIdentifier, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, feedbackIdentifier := range repository.feedbackIdentifiers {
+		if feedbackIdentifier.SubscriberUuid == uuid {
+			feedbackIdentifiers = append(feedbackIdentifiers, feedbackIdentifier)
+		}
+	}
+
+	sort.Slice(feedbackIdentifiers, func(i, j int) bool {
+		return feedbackIdentifiers[i].Key < feedbackIdentifiers[j].Key
+	})
+
+	return feedbackIdentifiers, nil
+}
+
+func (repository *memoryFeedbackKeyRepository) Remove(feedbackIdentifier *FeedbackIdentifier) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	if _, found := repository.feedbackIdentifiers[feedbackIdentifier.Key]; !found {
+		return ErrNotFound
+	}
+
+	delete(repository.feedbackIdentifiers, feedbackIdentifier.Key)
+	return nil
+}
+
+func (repository *memoryMoodRepository) Save(dailyMoods *DailyMoods) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	dailyMoods.Day = dailyMoods.SortKey()
+	repository.history[dailyMoods.DateString] = *copyDailyMoods(*dailyMoods)
+	return nil
+}
+
+func (repository *memoryMoodRepository) ByDate(dateString string) (*DailyMoods, error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	if dailyMoods, found := repository.history[dateString]; found {
+		return copyDailyMoods(dailyMoods), nil
+	}
+	return nil, ErrNotFound
+}
+
+func (repository *memoryMoodRepository) All() (history []DailyMoods, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, dailyMoods := range repository.history {
+		history = append(history, *copyDailyMoods(dailyMoods))
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return history[i].DateString < history[j].DateString
+	})
+
+	return history, nil
+}
+
+func (repository *memoryMoodRepository) Between(fromDay string, toDay string, limit int, reverse bool) (history []DailyMoods, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, dailyMoods := range repository.history {
+		if dailyMoods.Day >= fromDay && dailyMoods.Day <= toDay {
+			history = append(history, *copyDailyMoods(dailyMoods))
+		}
+	}
+
+	sort.Slice(history, func(i, j int) bool {
+		return (history[i].Day < history[j].Day) != reverse
+	})
+
+	if limit > 0 && limit < len(history) {
+		history = history[:limit]
+	}
+
+	return history, nil
+}
+
+func (repository *memoryMoodRepository) Count(fromDay string, toDay string) (count int, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, dailyMoods := range repository.history {
+		if dailyMoods.Day >= fromDay && dailyMoods.Day <= toDay {
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+func copyDailyMoods(dailyMoods DailyMoods) *DailyMoods {
+	counts := map[int]int{}
+	for value, count := range dailyMoods.Counts {
+		counts[value] = count
+	}
+
+	dailyMoods.Counts = counts
+
+	if dailyMoods.Weights != nil {
+		weights := map[int]float64{}
+		for value, weight := range dailyMoods.Weights {
+			weights[value] = weight
+		}
+		dailyMoods.Weights = weights
+	}
+
+	if dailyMoods.Samples != nil {
+		dailyMoods.Samples = append([]MoodSample{}, dailyMoods.Samples...)
+	}
+
+	return &dailyMoods
+}
+
+func (repository *memoryMailTaskRepository) Save(delivery *MailDelivery) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	repository.deliveries[delivery.Id] = *delivery
+	return nil
+}
+
+func (repository *memoryMailTaskRepository) BySubscriber(uuid string) (deliveries []MailDelivery, databaseError error) {
+	repository.lock.RLock()
+	defer repository.lock.RUnlock()
+
+	for _, delivery := range repository.deliveries {
+		if delivery.SubscriberUuid == uuid {
+			deliveries = append(deliveries, delivery)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].SentAt.Before(deliveries[j].SentAt)
+	})
+
+	return deliveries, nil
+}
+
+func (repository *memoryMailTaskRepository) Remove(delivery *MailDelivery) error {
+	repository.lock.Lock()
+	defer repository.lock.Unlock()
+
+	if _, found := repository.deliveries[delivery.Id]; !found {
+		return ErrNotFound
+	}
+
+	delete(repository.deliveries, delivery.Id)
+	return nil
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/index"
+	"github.com/asdine/storm/q"
+	"regexp"
+	"time"
+)
+
+type (
+	stormSubscriberRepository struct {
+		node storm.Node
+	}
+
+	stormFeedbackKeyRepository struct {
+		node storm.Node
+	}
+
+	stormMoodRepository struct {
+		node storm.Node
+	}
+
+	stormMailTaskRepository struct {
+		node storm.Node
+	}
+
+	feedbackKeyBefore struct {
+		cutoff time.Time
+	}
+)
+
+func newStormRepositories(node storm.Node) Repositories {
+	return Repositories{
+		Subscribers:  stormSubscriberRepository{node},
+		FeedbackKeys: stormFeedbackKeyRepository{node},
+		Moods:        stormMoodRepository{node},
+		MailTasks:    stormMailTaskRepository{node},
+		Clock:        systemClock{},
+		Mailer:       mailgunMailer{},
+	}
+}
+
+func (repository stormSubscriberRepository) Save(subscriber *Subscriber) error {
+	return repository.node.Save(subscriber)
+}
+
+func (repository stormSubscriberRepository) ByUuid(uuid string) (subscriber *Subscriber, databaseError error) {
+	subscriber = new(Subscriber)
+	databaseError = repository.node.One("Uuid", uuid, subscriber)
+	return subscriber, databaseError
+}
+
+func (repository stormSubscriberRepository) ByEmail(email string) (subscriber *Subscriber, databaseError error) {
+	subscriber = new(Subscriber)
+	databaseError = repository.node.One("Email", email, subscriber)
+	return subscriber, databaseError
+}
+
+func (repository stormSubscriberRepository) All() (subscribers []Subscriber, databaseError error) {
+	databaseError = repository.node.All(&subscribers)
+	return subscribers, databaseError
+}
+
+func (repository stormSubscriberRepository) Page(filter SubscriberFilter, page Page) (subscribers []Subscriber, next string, databaseError error) {
+	lower, upper := filter.EmailPrefix, filter.EmailPrefix+lastKey
+	after := page.Cursor
+
+	for len(subscribers) <= page.Limit {
+		var batch []Subscriber
+
+		if page.Reverse && after != "" && after < upper {
+			upper = after
+		} else if !page.Reverse && after > lower {
+			lower = after
+		}
+
+		databaseError = repository.node.Range("Email", lower, upper, &batch, rangeOptions(page.Limit+2, page.Reverse)...)
+
+		if databaseError == storm.ErrNotFound {
+			break
+		} else if databaseError != nil {
+			return nil, "", databaseError
+		}
+
+		for _, subscriber := range batch {
+			if subscriber.Email == after {
+				continue
+			}
+
+			after = subscriber.Email
+
+			if filter.matches(subscriber) {
+				subscribers = append(subscribers, subscriber)
+			}
+		}
+
+		if len(batch) < page.Limit+2 {
+			break
+		}
+	}
+
+	if len(subscribers) > page.Limit {
+		subscribers = subscribers[:page.Limit]
+		next = subscribers[page.Limit-1].Email
+	}
+
+	return subscribers, next, nil
+}
+
+func (repository stormSubscriberRepository) Count(filter SubscriberFilter) (int, error) {
+	var matchers []q.Matcher
+
+	if filter.EmailPrefix != "" {
+		matchers = append(matchers, q.Re("Email", "^"+regexp.QuoteMeta(filter.EmailPrefix)))
+	}
+	if filter.Status != "" {
+		matchers = append(matchers, q.Eq("Status", filter.Status))
+	}
+
+	if len(matchers) == 0 {
+		return repository.node.Count(&Subscriber{})
+	}
+	return repository.node.Select(matchers...).Count(&Subscriber{})
+}
+
+func (repository stormSubscriberRepository) Remove(subscriber *Subscriber) error {
+	return repository.node.Remove(subscriber)
+}
+
+func (repository stormFeedbackKeyRepository) Save(feedbackIdentifier *FeedbackIdentifier) error {
+	return repository.node.Save(feedbackIdentifier)
+}
+
+func (repository stormFeedbackKeyRepository) ByKey(key string) (feedbackIdentifier *FeedbackIdentifier, databaseError error) {
+	feedbackIdentifier = new(FeedbackIdentifier)
+	databaseError = repository.node.One("Key", key, feedbackIdentifier)
+	return feedbackIdentifier, databaseError
+}
+
+func (matcher feedbackKeyBefore) Match(record interface{}) (bool, error) {
+	feedbackIdentifier, isFeedbackIdentifier := record.(*FeedbackIdentifier)
+	return isFeedbackIdentifier && feedbackIdentifier.Date().Before(matcher.cutoff), nil
+}
+
+func (repository stormFeedbackKeyRepository) Before(cutoff time.Time, skip int, limit int) (feedbackIdentifiers []FeedbackIdentifier, databaseError error) {
+	databaseError = repository.node.Select(feedbackKeyBefore{cutoff}).Skip(skip).Limit(limit).Find(&feedbackIdentifiers)
+
+	if databaseError == storm.ErrNotFound {
+		return nil, nil
+	}
+	return feedbackIdentifiers, databaseError
+}
+
+func (repository stormFeedbackKeyRepository) BySubscriber(uuid string) (feedbackIdentifiers []FeedbackIdentifier, databaseError error) {
+	databaseError = repository.node.Find("SubscriberUuid", uuid, &feedbackIdentifiers)
+
+	if databaseError == storm.ErrNotFound {
+		return nil, nil
+	}
+	return feedbackIdentifiers, databaseError
+}
+
+func (repository stormFeedbackKeyRepository) Remove(feedbackIdentifier *FeedbackIdentifier) error {
+	return repository.node.Remove(feedbackIdentifier)
+}
+
+func (repository stormMoodRepository) Save(dailyMoods *DailyMoods) error {
+	dailyMoods.Day = dailyMoods.SortKey()
+	return repository.node.Save(dailyMoods)
+}
+
+func (repository stormMoodRepository) ByDate(dateString string) (dailyMoods *DailyMoods, databaseError error) {
+	dailyMoods = new(DailyMoods)
+	databaseError = repository.node.One("DateString", dateString, dailyMoods)
+	return dailyMoods, databaseError
+}
+
+func (repository stormMoodRepository) All() (history []DailyMoods, databaseError error) {
+	databaseError = repository.node.All(&history)
+	return history, databaseError
+}
+
+func (repository stormMoodRepository) Between(fromDay string, toDay string, limit int, reverse bool) (history []DailyMoods, databaseError error) {
+	databaseError = repository.node.Range("Day", fromDay, toDay, &history, rangeOptions(limit, reverse)...)
+
+	if databaseError == storm.ErrNotFound {
+		return nil, nil
+	}
+	return history, databaseError
+}
+
+func (repository stormMoodRepository) Count(fromDay string, toDay string) (count int, databaseError error) {
+	count, databaseError = repository.node.Select(q.Gte("Day", fromDay), q.Lte("Day", toDay)).Count(&DailyMoods{})
+
+	if databaseError == storm.ErrNotFound {
+		return 0, nil
+	}
+	return count, databaseError
+}
+
+func rangeOptions(limit int, reverse bool) (options []func(*index.Options)) {
+	if limit > 0 {
+		options = append(options, storm.Limit(limit))
+	}
+	if reverse {
+		options = append(options, storm.Reverse())
+	}
+	return options
+}
+
+func (repository stormMailTaskRepository) Save(delivery *MailDelivery) error {
+	return repository.node.Save(delivery)
+}
+
+func (repository stormMailTaskRepository) BySubscriber(uuid string) (deliveries []MailDelivery, databaseError error) {
+	databaseError = repository.node.Find("SubscriberUuid", uuid, &deliveries)
+
+	if databaseError == storm.ErrNotFound {
+		return nil, nil
+	}
+	return deliveries, databaseError
+}
+
+func (repository stormMailTaskRepository) Remove(delivery *MailDelivery) error {
+	return repository.node.Remove(delivery)
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"github.com/nu7hatch/gouuid"
+	"log"
+	"net/http"
+	"time"
+)
+
+const (
+	ChannelWeb   = "web"
+	ChannelReply = "reply"
+	ChannelGrpc  = "grpc"
+)
+
+type (
+	Response struct {
+		Id            string    `json:"id" storm:"id"`
+		DateString    string    `json:"date" storm:"index"`
+		SurveyId      string    `json:"survey"`
+		SurveyVersion int       `json:"survey-version"`
+		Answers       Answers   `json:"answers"`
+		SubmittedAt   time.Time `json:"submitted-at"`
+		Channel       string    `json:"channel"`
+		Team          string    `json:"team,omitempty"`
+		Weight        float64   `json:"weight,omitempty"`
+	}
+
+	RebuildReport struct {
+		Rebuilt []string `json:"rebuilt"`
+		Skipped []string `json:"skipped"`
+	}
+)
+
+func newResponse(feedbackIdentifier *FeedbackIdentifier, survey *Survey, answers Answers, channel string, submittedAt time.Time) Response {
+	id, _ := uuid.NewV4()
+
+	return Response{
+		Id:            id.String(),
+		DateString:    feedbackIdentifier.DateString,
+		SurveyId:      survey.Id,
+		SurveyVersion: survey.Version,
+		Answers:       answers,
+		SubmittedAt:   submittedAt,
+		Channel:       channel,
+		Team:          feedbackIdentifier.Team,
+		Weight:        feedbackIdentifier.Weight,
+	}
+}
+
+func applyResponse(database storm.Node, repositories Repositories, dailyMoods *DailyMoods, survey *Survey, response Response) error {
+	if moods := response.Answers[MoodQuestionId]; len(moods) > 0 {
+		if databaseError := updateDailyMoods(repositories.Moods, dailyMoods.DateString, moods[0], response.Weight); databaseError != nil {
+			return databaseError
+		}
+	}
+
+	if !survey.hasOnlyMoodQuestion() {
+		return saveSurveyResults(database, dailyMoods, survey, response.Answers)
+	}
+
+	return nil
+}
+
+func getResponsesByDate(database storm.Node, dateString string) (responses []Response, databaseError error) {
+	databaseError = database.Find("DateString", dateString, &responses)
+
+	if databaseError == storm.ErrNotFound {
+		return nil, nil
+	}
+	return responses, databaseError
+}
+
+func rebuildRollup(database *storm.DB, repositories Repositories, dateString string) (rebuilt bool, databaseError error) {
+	transaction, databaseError := database.Begin(true)
+
+	if databaseError != nil {
+		return false, databaseError
+	}
+
+	defer transaction.Rollback()
+
+	transactional := newStormRepositories(transaction)
+	transactional.Organization, transactional.Clock, transactional.Mailer = repositories.Organization, repositories.Clock, repositories.Mailer
+
+	if rebuilt, databaseError = replayResponses(transaction, transactional, dateString); databaseError != nil || !rebuilt {
+		return false, databaseError
+	}
+
+	return true, transaction.Commit()
+}
+
+func replayResponses(database storm.Node, repositories Repositories, dateString string) (rebuilt bool, databaseError error) {
+	dailyMoods, databaseError := getDailyMoodsByDate(repositories.Moods, dateString)
+
+	if databaseError != nil {
+		return false, databaseError
+	}
+
+	if !dailyMoods.FromResponses {
+		return false, nil
+	}
+
+	survey, databaseError := getSurveyOfDay(database, dailyMoods)
+
+	if databaseError != nil {
+		return false, databaseError
+	}
+
+	responses, databaseError := getResponsesByDate(database, dateString)
+
+	if databaseError != nil {
+		return false, databaseError
+	}
+
+	dailyMoods.Counts = map[int]int{}
+	dailyMoods.Weights = map[int]float64{}
+	dailyMoods.Samples = []MoodSample{}
+
+	if databaseError = repositories.Moods.Save(dailyMoods); databaseError != nil {
+		return false, databaseError
+	}
+
+	if databaseError = database.Remove(&SurveyResults{DateString: dateString}); databaseError != nil && databaseError != storm.ErrNotFound {
+		return false, databaseError
+	}
+
+	for _, response := range responses {
+		if databaseError = applyResponse(database, repositories, dailyMoods, survey, response); databaseError != nil {
+			return false, databaseError
+		}
+	}
+
+	return true, nil
+}
+
+func rebuildRollups(database *storm.DB, repositories Repositories, dateStrings []string) (report RebuildReport, databaseError error) {
+	report = RebuildReport{[]string{}, []string{}}
+
+	for _, dateString := range dateStrings {
+		rebuilt, databaseError := rebuildRollup(database, repositories, dateString)
+
+		if databaseError != nil {
+			return report, databaseError
+		}
+
+		if rebuilt {
+			report.Rebuilt = append(report.Rebuilt, dateString)
+		} else {
+			report.Skipped = append(report.Skipped, dateString)
+		}
+	}
+
+	log.Printf("Rebuilt %d rollups, skipped %d without responses.", len(report.Rebuilt), len(report.Skipped))
+
+	return report, nil
+}
+
+func getResponses(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		dateString := context.QueryParam("date")
+
+		if dateString == "" {
+			return context.String(http.StatusBadRequest, "Query parameter 'date' is required!")
+		}
+
+		responses, databaseError := getResponsesByDate(database, dateString)
+
+		if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, responses)
+		}
+	})
+}
+
+func deleteResponse(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		response := new(Response)
+
+		transaction, databaseError := database.Begin(true)
+
+		if databaseError != nil {
+			return databaseError
+		}
+
+		defer transaction.Rollback()
+
+		transactional := newStormRepositories(transaction)
+		transactional.Organization, transactional.Clock, transactional.Mailer = repositories.Organization, repositories.Clock, repositories.Mailer
+
+		if databaseError = transaction.One("Id", id, response); databaseError != nil {
+			return context.String(http.StatusNotFound, "Response with id '"+id+"' not found!")
+		}
+
+		if databaseError = transaction.Remove(response); databaseError != nil {
+			return databaseError
+		}
+
+		if _, databaseError = replayResponses(transaction, transactional, response.DateString); databaseError != nil {
+			return databaseError
+		}
+
+		if databaseError = transaction.Commit(); databaseError != nil {
+			return databaseError
+		}
+
+		return context.NoContent(http.StatusNoContent)
+	})
+}
+
+func postRebuildRollups(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		var dateStrings []string
+
+		if dateString := context.QueryParam("date"); dateString != "" {
+			dateStrings = []string{dateString}
+		} else {
+			history, databaseError := getAllDailyMoods(repositories.Moods)
+
+			if databaseError != nil {
+				return databaseError
+			}
+
+			for _, dailyMoods := range history {
+				dateStrings = append(dateStrings, dailyMoods.DateString)
+			}
+		}
+
+		if report, databaseError := rebuildRollups(database, repositories, dateStrings); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, report)
+		}
+	})
+}
@@ -0,0 +1,337 @@
+package main
+
+import (
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/labstack/echo"
+	"log"
+	"net/http"
+	"time"
+)
+
+type (
+	PurgeReport struct {
+		DryRun            bool      `json:"dry-run"`
+		FeedbackKeys      int       `json:"feedback-keys"`
+		MailDeliveries    int       `json:"mail-deliveries"`
+		WebhookDeliveries int       `json:"webhook-deliveries"`
+		Comments          int       `json:"comments"`
+		Responses         int       `json:"responses"`
+		StartedAt         time.Time `json:"started-at"`
+		FinishedAt        time.Time `json:"finished-at"`
+	}
+
+	batchLoader func(node storm.Node, skip int) ([]interface{}, error)
+	batchPurger func(node storm.Node, record interface{}) error
+)
+
+var FeedbackKeyRetentionDays int = getEnvInt("MUT_RETENTION_FEEDBACK_KEYS_DAYS", 30)
+var MailLogRetentionDays int = getEnvInt("MUT_RETENTION_MAIL_LOG_DAYS", 90)
+var WebhookLogRetentionDays int = getEnvInt("MUT_RETENTION_WEBHOOK_LOG_DAYS", 30)
+var CommentRetentionDays int = getEnvInt("MUT_RETENTION_COMMENTS_DAYS", 365)
+var ResponseRetentionDays int = getEnvInt("MUT_RETENTION_RESPONSES_DAYS", 0)
+var PurgeBatchSize int = getEnvInt("MUT_PURGE_BATCH_SIZE", 500)
+var PurgeDryRun bool = getEnvString("MUT_PURGE_DRY_RUN", "false") == "true"
+
+func retentionCutoff(clock Clock, days int) (cutoff time.Time, enabled bool) {
+	if days <= 0 {
+		return cutoff, false
+	}
+	return clock.Now().AddDate(0, 0, -days), true
+}
+
+func purgeInBatches(database *storm.DB, dryRun bool, removesRecords bool, load batchLoader, purge batchPurger) (purged int, databaseError error) {
+	skip := 0
+
+	for {
+		var batch []interface{}
+
+		if dryRun {
+			if batch, databaseError = load(database, skip); databaseError != nil {
+				return purged, databaseError
+			}
+		} else if batch, databaseError = purgeBatch(database, skip, load, purge); databaseError != nil {
+			return purged, databaseError
+		}
+
+		purged += len(batch)
+
+		if len(batch) < PurgeBatchSize {
+			return purged, nil
+		}
+
+		if dryRun || !removesRecords {
+			skip += len(batch)
+		}
+	}
+}
+
+func purgeBatch(database *storm.DB, skip int, load batchLoader, purge batchPurger) (batch []interface{}, databaseError error) {
+	transaction, databaseError := database.Begin(true)
+
+	if databaseError != nil {
+		return nil, databaseError
+	}
+
+	defer transaction.Rollback()
+
+	if batch, databaseError = load(transaction, skip); databaseError != nil {
+		return nil, databaseError
+	}
+
+	for _, record := range batch {
+		if databaseError = purge(transaction, record); databaseError != nil {
+			return nil, databaseError
+		}
+	}
+
+	return batch, transaction.Commit()
+}
+
+func ignoreNotFound(databaseError error) error {
+	if databaseError == storm.ErrNotFound {
+		return nil
+	}
+	return databaseError
+}
+
+func removeRecord(node storm.Node, record interface{}) error {
+	return node.Remove(record)
+}
+
+func getDatesBefore(moods MoodRepository, cutoff time.Time) (dateStrings []string, databaseError error) {
+	history, databaseError := getAllDailyMoods(moods)
+
+	for _, dailyMoods := range history {
+		if dailyMoods.Date().Before(cutoff) {
+			dateStrings = append(dateStrings, dailyMoods.DateString)
+		}
+	}
+
+	return dateStrings, databaseError
+}
+
+func loadFeedbackIdentifiers(cutoff time.Time) batchLoader {
+	return func(node storm.Node, skip int) ([]interface{}, error) {
+		feedbackIdentifiers, databaseError := newStormRepositories(node).FeedbackKeys.Before(cutoff, skip, PurgeBatchSize)
+
+		batch := make([]interface{}, 0, len(feedbackIdentifiers))
+		for index := range feedbackIdentifiers {
+			batch = append(batch, &feedbackIdentifiers[index])
+		}
+		return batch, ignoreNotFound(databaseError)
+	}
+}
+
+func loadResponses(dateString string) batchLoader {
+	return func(node storm.Node, skip int) ([]interface{}, error) {
+		var responses []Response
+		databaseError := node.Find("DateString", dateString, &responses, storm.Limit(PurgeBatchSize), storm.Skip(skip))
+
+		batch := make([]interface{}, 0, len(responses))
+		for index := range responses {
+			batch = append(batch, &responses[index])
+		}
+		return batch, ignoreNotFound(databaseError)
+	}
+}
+
+func loadMailDeliveries(cutoff time.Time) batchLoader {
+	return func(node storm.Node, skip int) ([]interface{}, error) {
+		var deliveries []MailDelivery
+		databaseError := node.Select(q.Lt("SentAt", cutoff)).Skip(skip).Limit(PurgeBatchSize).Find(&deliveries)
+
+		batch := make([]interface{}, 0, len(deliveries))
+		for index := range deliveries {
+			batch = append(batch, &deliveries[index])
+		}
+		return batch, ignoreNotFound(databaseError)
+	}
+}
+
+func loadWebhookDeliveries(cutoff time.Time) batchLoader {
+	return func(node storm.Node, skip int) ([]interface{}, error) {
+		var deliveries []WebhookDelivery
+		databaseError := node.Select(q.Lt("CreatedAt", cutoff), q.Not(q.Eq("Status", DeliveryPending))).Skip(skip).Limit(PurgeBatchSize).Find(&deliveries)
+
+		batch := make([]interface{}, 0, len(deliveries))
+		for index := range deliveries {
+			batch = append(batch, &deliveries[index])
+		}
+		return batch, ignoreNotFound(databaseError)
+	}
+}
+
+func stripComments(survey *Survey) batchPurger {
+	return func(node storm.Node, record interface{}) error {
+		response := record.(*Response)
+
+		for _, question := range survey.Questions {
+			if question.Type == QuestionFreeText {
+				delete(response.Answers, question.Id)
+			}
+		}
+
+		return node.Save(response)
+	}
+}
+
+func purgeFeedbackKeys(database *storm.DB, repositories Repositories, report *PurgeReport) error {
+	cutoff, enabled := retentionCutoff(repositories.Clock, FeedbackKeyRetentionDays)
+
+	if !enabled {
+		return nil
+	}
+
+	purged, databaseError := purgeInBatches(database, report.DryRun, true, loadFeedbackIdentifiers(cutoff), removeRecord)
+	report.FeedbackKeys += purged
+
+	return databaseError
+}
+
+func purgeMailDeliveries(database *storm.DB, repositories Repositories, report *PurgeReport) (databaseError error) {
+	cutoff, enabled := retentionCutoff(repositories.Clock, MailLogRetentionDays)
+
+	if !enabled {
+		return nil
+	}
+
+	report.MailDeliveries, databaseError = purgeInBatches(database, report.DryRun, true, loadMailDeliveries(cutoff), removeRecord)
+	return databaseError
+}
+
+func purgeWebhookDeliveries(database *storm.DB, repositories Repositories, report *PurgeReport) (databaseError error) {
+	cutoff, enabled := retentionCutoff(repositories.Clock, WebhookLogRetentionDays)
+
+	if !enabled {
+		return nil
+	}
+
+	report.WebhookDeliveries, databaseError = purgeInBatches(database, report.DryRun, true, loadWebhookDeliveries(cutoff), removeRecord)
+	return databaseError
+}
+
+func purgeComments(database *storm.DB, repositories Repositories, report *PurgeReport) error {
+	cutoff, enabled := retentionCutoff(repositories.Clock, CommentRetentionDays)
+
+	if !enabled {
+		return nil
+	}
+
+	dateStrings, databaseError := getDatesBefore(repositories.Moods, cutoff)
+
+	for _, dateString := range dateStrings {
+		results := new(SurveyResults)
+		databaseError = database.One("DateString", dateString, results)
+
+		if databaseError == storm.ErrNotFound {
+			continue
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		comments := 0
+		for _, aggregate := range results.Answers {
+			comments += len(aggregate.Texts)
+			aggregate.Texts = nil
+		}
+
+		if comments == 0 {
+			continue
+		}
+		report.Comments += comments
+
+		if !report.DryRun {
+			if databaseError = database.Save(results); databaseError != nil {
+				return databaseError
+			}
+		}
+
+		survey, databaseError := getSurvey(database, results.SurveyId, results.SurveyVersion)
+
+		if databaseError != nil {
+			return databaseError
+		}
+
+		if _, databaseError = purgeInBatches(database, report.DryRun, false, loadResponses(dateString), stripComments(survey)); databaseError != nil {
+			return databaseError
+		}
+	}
+
+	return nil
+}
+
+func purgeResponses(database *storm.DB, repositories Repositories, report *PurgeReport) error {
+	cutoff, enabled := retentionCutoff(repositories.Clock, ResponseRetentionDays)
+
+	if !enabled {
+		return nil
+	}
+
+	dateStrings, databaseError := getDatesBefore(repositories.Moods, cutoff)
+
+	for _, dateString := range dateStrings {
+		purged, databaseError := purgeInBatches(database, report.DryRun, true, loadResponses(dateString), removeRecord)
+		report.Responses += purged
+
+		if databaseError != nil {
+			return databaseError
+		}
+
+		if purged > 0 && !report.DryRun {
+			if databaseError = freezeRollup(repositories.Moods, dateString); databaseError != nil {
+				return databaseError
+			}
+		}
+	}
+
+	return databaseError
+}
+
+func freezeRollup(moods MoodRepository, dateString string) error {
+	dailyMoods, databaseError := getDailyMoodsByDate(moods, dateString)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	dailyMoods.FromResponses = false
+	return moods.Save(dailyMoods)
+}
+
+func purge(database *storm.DB, repositories Repositories, dryRun bool) (report PurgeReport, databaseError error) {
+	report.DryRun = dryRun
+	report.StartedAt = repositories.Clock.Now()
+
+	for _, step := range []func(*storm.DB, Repositories, *PurgeReport) error{purgeFeedbackKeys, purgeMailDeliveries, purgeWebhookDeliveries, purgeComments, purgeResponses} {
+		if databaseError = step(database, repositories, &report); databaseError != nil {
+			break
+		}
+	}
+
+	report.FinishedAt = repositories.Clock.Now()
+	log.Printf("Purge (dry run: %t) removed %d feedback keys, %d mail deliveries, %d webhook deliveries, %d comments and %d responses.",
+		report.DryRun, report.FeedbackKeys, report.MailDeliveries, report.WebhookDeliveries, report.Comments, report.Responses)
+
+	return report, databaseError
+}
+
+func purgeStaleRecords(database *storm.DB, repositories Repositories) func() {
+	return func() {
+		if _, databaseError := purge(database, repositories, PurgeDryRun); databaseError != nil {
+			log.Printf("%s", databaseError)
+		}
+	}
+}
+
+func postPurge(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		report, databaseError := purge(database, repositories, context.QueryParam("dry-run") == "true")
+
+		if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, report)
+		}
+	})
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"errors"
+	"log"
+	"strconv"
+)
+
+type (
+	ScalePoint struct {
+		Value int    `json:"value"`
+		Label string `json:"label"`
+		Emoji string `json:"emoji"`
+	}
+
+	Scale struct {
+		Name   string       `json:"name"`
+		Points []ScalePoint `json:"points"`
+	}
+)
+
+var ErrInvalidMood = errors.New("invalid mood")
+
+var Scales = map[string]Scale{
+	"3-point": {"3-point", []ScalePoint{
+		{0, "Unhappy", "🙁"},
+		{1, "Neutral", "😐"},
+		{2, "Happy", "🙂"},
+	}},
+	"5-point": {"5-point", []ScalePoint{
+		{0, "Very unhappy", "😞"},
+		{1, "Unhappy", "🙁"},
+		{2, "Neutral", "😐"},
+		{3, "Happy", "🙂"},
+		{4, "Very happy", "😄"},
+	}},
+	"7-point": {"7-point", []ScalePoint{
+		{0, "Miserable", "😫"},
+		{1, "Very unhappy", "😞"},
+		{2, "Unhappy", "🙁"},
+		{3, "Neutral", "😐"},
+		{4, "Happy", "🙂"},
+		{5, "Very happy", "😄"},
+		{6, "Excellent", "🤩"},
+	}},
+	"10-point": {"10-point", []ScalePoint{
+		{1, "Terrible", "😫"},
+		{2, "Very bad", "😞"},
+		{3, "Bad", "😟"},
+		{4, "Poor", "🙁"},
+		{5, "Mediocre", "😕"},
+		{6, "Okay", "😐"},
+		{7, "Good", "🙂"},
+		{8, "Very good", "😊"},
+		{9, "Great", "😄"},
+		{10, "Excellent", "🤩"},
+	}},
+}
+
+var LegacyScale Scale = Scales["5-point"]
+var DefaultScale Scale = getDefaultScale()
+
+func getDefaultScale() Scale {
+	name := getEnvString("MUT_SCALE", LegacyScale.Name)
+
+	if scale, found := Scales[name]; found {
+		return scale
+	} else {
+		log.Printf("Unknown scale '%s', falling back to '%s'.", name, LegacyScale.Name)
+		return LegacyScale
+	}
+}
+
+func (scale Scale) ParseValue(mood string) (int, error) {
+	value, parseError := strconv.Atoi(mood)
+
+	if parseError != nil || !scale.HasValue(value) {
+		return 0, ErrInvalidMood
+	}
+
+	return value, nil
+}
+
+func (scale Scale) HasValue(value int) bool {
+	for _, point := range scale.Points {
+		if point.Value == value {
+			return true
+		}
+	}
+	return false
+}
+
+func (scale Scale) Lowest() int {
+	return scale.Points[0].Value
+}
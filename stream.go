@@ -0,0 +1,252 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine/fasthttp"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const EventStreamReset = "reset"
+
+type (
+	streamClients struct {
+		lock  sync.Mutex
+		perIp map[string]int
+	}
+
+	VoteCount struct {
+		DateString string `json:"date"`
+		Responses  int    `json:"responses"`
+	}
+)
+
+var StreamHeartbeat time.Duration = time.Duration(getEnvInt("MUT_STREAM_HEARTBEAT_SECONDS", 15)) * time.Second
+var StreamRetryMilliseconds int = getEnvInt("MUT_STREAM_RETRY_MILLISECONDS", 5000)
+var StreamMaxClients int = getEnvInt("MUT_STREAM_MAX_CLIENTS", 100)
+var StreamMaxClientsPerIp int = getEnvInt("MUT_STREAM_MAX_CLIENTS_PER_IP", 2)
+
+var openStreams = &streamClients{perIp: map[string]int{}}
+
+func (clients *streamClients) acquire(ip string) bool {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if clients.perIp[ip] >= StreamMaxClientsPerIp {
+		return false
+	}
+
+	clients.perIp[ip]++
+	return true
+}
+
+func (clients *streamClients) release(ip string) {
+	clients.lock.Lock()
+	defer clients.lock.Unlock()
+
+	if clients.perIp[ip]--; clients.perIp[ip] <= 0 {
+		delete(clients.perIp, ip)
+	}
+}
+
+func isPeriodComplete(published PublishedMoods, closed time.Time) bool {
+	switch published.Period {
+	case PeriodWeek:
+		return closed.Equal(endOfWeek(closed))
+	case PeriodMonth:
+		return closed.Equal(endOfMonth(closed))
+	}
+	return true
+}
+
+func getReleasedMoods(moods MoodRepository, dateString string) (released *PublishedMoods, databaseError error) {
+	closed, parseError := time.Parse(dateLayout, dateString)
+
+	if parseError != nil {
+		return nil, nil
+	}
+
+	history, databaseError := getMonthHistory(moods, closed)
+
+	if databaseError != nil {
+		return nil, databaseError
+	}
+
+	for _, published := range publishMoods(history) {
+		if published.Until != dateString || !isPeriodComplete(published, closed) {
+			continue
+		}
+		return &published, nil
+	}
+
+	return nil, nil
+}
+
+func getMonthHistory(moods MoodRepository, date time.Time) ([]DailyMoods, error) {
+	return moods.Between(startOfWeek(startOfMonth(date)).Format(dayLayout), endOfWeek(endOfMonth(date)).Format(dayLayout), 0, false)
+}
+
+func getReleasedVoteCount(moods MoodRepository, dateString string) (released *VoteCount, databaseError error) {
+	dailyMoods, databaseError := moods.ByDate(dateString)
+
+	if databaseError == ErrNotFound {
+		return nil, nil
+	} else if databaseError != nil {
+		return nil, databaseError
+	}
+
+	for _, published := range publishMoods([]DailyMoods{*dailyMoods}) {
+		if published.Period == PeriodDay {
+			return &VoteCount{dateString, published.Total()}, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func writeStreamEvent(writer *bufio.Writer, id string, eventType string, data interface{}) error {
+	payload, jsonError := json.Marshal(data)
+
+	if jsonError != nil {
+		return jsonError
+	}
+
+	if id != "" {
+		fmt.Fprintf(writer, "id: %s\n", id)
+	}
+	_, writeError := fmt.Fprintf(writer, "event: %s\ndata: %s\n\n", eventType, payload)
+	return writeError
+}
+
+func writeMoodEvent(writer *bufio.Writer, hub *EventHub, moods MoodRepository, event HubEvent) error {
+	var released interface{}
+	var databaseError error
+
+	switch data := event.Data.(type) {
+	case DayClosedEvent:
+		if moods, releaseError := getReleasedMoods(moods, data.DateString); moods != nil {
+			released = moods
+		} else {
+			databaseError = releaseError
+		}
+	case VoteRecordedEvent:
+		if count, releaseError := getReleasedVoteCount(moods, data.DateString); count != nil {
+			released = count
+		} else {
+			databaseError = releaseError
+		}
+	}
+
+	if databaseError != nil {
+		log.Printf("%s", databaseError)
+		return nil
+	} else if released == nil {
+		return nil
+	}
+
+	return writeStreamEvent(writer, hub.EventId(event.Sequence), event.Type, released)
+}
+
+func streamMoods(writer *bufio.Writer, hub *EventHub, moods MoodRepository, events chan HubEvent, missed []HubEvent, reset bool, ip string) {
+	defer openStreams.release(ip)
+	defer hub.Unsubscribe(events)
+
+	fmt.Fprintf(writer, "retry: %d\n\n", StreamRetryMilliseconds)
+
+	if reset {
+		writeStreamEvent(writer, "", EventStreamReset, struct{}{})
+	}
+
+	for _, event := range missed {
+		if writeError := writeMoodEvent(writer, hub, moods, event); writeError != nil {
+			return
+		}
+	}
+
+	if writer.Flush() != nil {
+		return
+	}
+
+	heartbeat := time.NewTicker(StreamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case event, open := <-events:
+			if !open {
+				return
+			}
+			if writeError := writeMoodEvent(writer, hub, moods, event); writeError != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, writeError := writer.WriteString(": ping\n\n"); writeError != nil {
+				return
+			}
+		}
+
+		if writer.Flush() != nil {
+			return
+		}
+	}
+}
+
+func getMoodStream(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		request, streamable := context.Request().(*fasthttp.Request)
+
+		if !streamable {
+			return context.String(http.StatusNotImplemented, "Streaming is only supported on the fasthttp engine!")
+		}
+
+		hub := getEventHub(database)
+
+		if hub.Subscribers() >= StreamMaxClients {
+			context.Response().Header().Set("Retry-After", retryAfterSeconds(StreamHeartbeat))
+			return context.String(http.StatusServiceUnavailable, "Too many open mood streams!")
+		}
+
+		ip := getClientIp(context)
+
+		if !openStreams.acquire(ip) {
+			context.Response().Header().Set("Retry-After", retryAfterSeconds(StreamHeartbeat))
+			return context.String(http.StatusTooManyRequests, "Too many open mood streams from your address!")
+		}
+
+		lastEventId := context.Request().Header().Get("Last-Event-ID")
+		if lastEventId == "" {
+			lastEventId = context.QueryParam("last-event-id")
+		}
+
+		var events chan HubEvent
+		var missed []HubEvent
+		reset := false
+
+		if lastEventId == "" {
+			events = hub.Subscribe()
+		} else if sequence, valid := hub.ParseEventId(lastEventId); valid {
+			var complete bool
+			events, missed, complete = hub.SubscribeAfter(sequence)
+			reset = !complete
+		} else {
+			events, reset = hub.Subscribe(), true
+		}
+
+		header := context.Response().Header()
+		header.Set("Content-Type", "text/event-stream")
+		header.Set("Cache-Control", "no-cache")
+		header.Set("X-Accel-Buffering", "no")
+
+		request.SetBodyStreamWriter(func(writer *bufio.Writer) {
+			streamMoods(writer, hub, repositories.Moods, events, missed, reset, ip)
+		})
+
+		return nil
+	})
+}
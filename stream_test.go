@@ -0,0 +1,61 @@
+package main
+
+import (
+	"testing"
+)
+
+func saveMoodsOfDay(t *testing.T, moods MoodRepository, dateString string, votes int) {
+	dailyMoods := DailyMoods{DateString: dateString, Scale: DefaultScale, Counts: map[int]int{}, Invited: votes}
+
+	for vote := 0; vote < votes; vote++ {
+		if addError := dailyMoods.AddMood("2", 1); addError != nil {
+			t.Fatal(addError)
+		}
+	}
+
+	if databaseError := moods.Save(&dailyMoods); databaseError != nil {
+		t.Fatal(databaseError)
+	}
+}
+
+func TestReleasedMoodsOnlyForCompletePeriods(t *testing.T) {
+	moods := newMemoryRepositories().Moods
+
+	saveMoodsOfDay(t, moods, "01-06-2026", MinimumResponses)
+	saveMoodsOfDay(t, moods, "02-06-2026", 1)
+	saveMoodsOfDay(t, moods, "07-06-2026", MinimumResponses-1)
+
+	released, databaseError := getReleasedMoods(moods, "01-06-2026")
+	expect(t, databaseError == nil && released != nil && released.Period == PeriodDay, "a day above the threshold must be released on its own")
+
+	released, databaseError = getReleasedMoods(moods, "02-06-2026")
+	expect(t, databaseError == nil && released == nil, "a day below the threshold must not be released before its week is complete")
+
+	released, databaseError = getReleasedMoods(moods, "07-06-2026")
+	expect(t, databaseError == nil && released != nil && released.Period == PeriodWeek && released.DateString == "02-06-2026", "the merged week must be released once its last day closes")
+}
+
+func TestReleasedWeekAcrossMonths(t *testing.T) {
+	moods := newMemoryRepositories().Moods
+
+	saveMoodsOfDay(t, moods, "29-06-2026", 2)
+	saveMoodsOfDay(t, moods, "30-06-2026", 2)
+	saveMoodsOfDay(t, moods, "05-07-2026", MinimumResponses-1)
+
+	released, databaseError := getReleasedMoods(moods, "05-07-2026")
+	expect(t, databaseError == nil && released != nil && released.Period == PeriodWeek && released.DateString == "29-06-2026", "the week must include the days of the previous month")
+	expect(t, released != nil && released.Total() == MinimumResponses+3, "the week must count every vote of the week, got %+v", released)
+}
+
+func TestReleasedVoteCount(t *testing.T) {
+	moods := newMemoryRepositories().Moods
+
+	saveMoodsOfDay(t, moods, "01-06-2026", MinimumResponses-1)
+	saveMoodsOfDay(t, moods, "02-06-2026", MinimumResponses)
+
+	count, databaseError := getReleasedVoteCount(moods, "01-06-2026")
+	expect(t, databaseError == nil && count == nil, "a vote count below the threshold must not be released")
+
+	count, databaseError = getReleasedVoteCount(moods, "02-06-2026")
+	expect(t, databaseError == nil && count != nil && count.Responses == MinimumResponses, "a vote count above the threshold must be released, got %+v", count)
+}
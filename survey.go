@@ -0,0 +1,599 @@
+package main
+
+import (
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/labstack/echo"
+	"html"
+	"math/rand"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	QuestionScale        = "scale"
+	QuestionSingleChoice = "single-choice"
+	QuestionMultiChoice  = "multi-choice"
+	QuestionFreeText     = "free-text"
+
+	DefaultSurveyId  = "mood"
+	MoodQuestionId   = "mood"
+	activeSurveyKey  = "active-survey"
+	settingsBucket   = "settings"
+	noiseSecretKey   = "noise-secret"
+	maximumTextInput = 2000
+)
+
+var shuffleLock sync.Mutex
+var shuffleSource = rand.New(rand.NewSource(time.Now().UnixNano()))
+
+type (
+	Question struct {
+		Id       string   `json:"id"`
+		Text     string   `json:"text"`
+		Type     string   `json:"type"`
+		Scale    string   `json:"scale,omitempty"`
+		Choices  []string `json:"choices,omitempty"`
+		Required bool     `json:"required"`
+		Order    int      `json:"order"`
+	}
+
+	Survey struct {
+		Key       string     `json:"-" storm:"id"`
+		Id        string     `json:"id" storm:"index"`
+		Version   int        `json:"version"`
+		Name      string     `json:"name"`
+		Subject   string     `json:"subject"`
+		Questions []Question `json:"questions"`
+		CreatedAt time.Time  `json:"created-at"`
+	}
+
+	QuestionAggregate struct {
+		Answered   int            `json:"answered"`
+		Counts     map[string]int `json:"counts,omitempty"`
+		Texts      []string       `json:"texts,omitempty"`
+		Suppressed bool           `json:"suppressed,omitempty"`
+	}
+
+	SurveyResults struct {
+		DateString    string                        `json:"date" storm:"id"`
+		SurveyId      string                        `json:"survey" storm:"index"`
+		SurveyVersion int                           `json:"version"`
+		Answers       map[string]*QuestionAggregate `json:"answers"`
+	}
+
+	Answers map[string][]string
+
+	AnswerError struct {
+		Message string
+	}
+)
+
+func surveyKey(id string, version int) string {
+	return id + "/" + strconv.Itoa(version)
+}
+
+func getDefaultSurvey() Survey {
+	return Survey{
+		Key:     surveyKey(DefaultSurveyId, 1),
+		Id:      DefaultSurveyId,
+		Version: 1,
+		Name:    "Daily mood",
+		Subject: "How is your mood today?",
+		Questions: []Question{
+			{Id: MoodQuestionId, Text: "Select your mood", Type: QuestionScale, Scale: DefaultScale.Name, Required: true},
+		},
+	}
+}
+
+func (survey *Survey) validate() error {
+	if survey.Id == "" || strings.Contains(survey.Id, "/") {
+		return fmt.Errorf("survey needs an id without '/'")
+	}
+
+	if len(survey.Questions) == 0 {
+		return fmt.Errorf("survey needs at least one question")
+	}
+
+	seen := map[string]bool{}
+
+	for index := range survey.Questions {
+		question := &survey.Questions[index]
+
+		if question.Id == "" || seen[question.Id] {
+			return fmt.Errorf("question %d needs a unique id", index)
+		}
+		seen[question.Id] = true
+
+		switch question.Type {
+		case QuestionScale:
+			if question.Scale == "" {
+				question.Scale = DefaultScale.Name
+			}
+			if _, found := Scales[question.Scale]; !found {
+				return fmt.Errorf("question '%s' uses unknown scale '%s'", question.Id, question.Scale)
+			}
+		case QuestionSingleChoice, QuestionMultiChoice:
+			if len(question.Choices) < 2 {
+				return fmt.Errorf("question '%s' needs at least two choices", question.Id)
+			}
+		case QuestionFreeText:
+		default:
+			return fmt.Errorf("question '%s' has unknown type '%s'", question.Id, question.Type)
+		}
+
+		if question.Id == MoodQuestionId && question.Type != QuestionScale {
+			return fmt.Errorf("question '%s' must be a scale question", MoodQuestionId)
+		}
+	}
+
+	sort.SliceStable(survey.Questions, func(i, j int) bool {
+		return survey.Questions[i].Order < survey.Questions[j].Order
+	})
+
+	if survey.Subject == "" {
+		survey.Subject = getDefaultSurvey().Subject
+	}
+
+	return nil
+}
+
+func (survey *Survey) MoodScale() (Scale, bool) {
+	for _, question := range survey.Questions {
+		if question.Id == MoodQuestionId {
+			return Scales[question.Scale], true
+		}
+	}
+	return Scale{}, false
+}
+
+func (survey *Survey) hasOnlyMoodQuestion() bool {
+	return len(survey.Questions) == 1 && survey.Questions[0].Id == MoodQuestionId
+}
+
+func (question *Question) validateAnswer(values []string) ([]string, error) {
+	var answers []string
+
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			answers = append(answers, value)
+		}
+	}
+
+	if len(answers) == 0 {
+		if question.Required {
+			return nil, fmt.Errorf("question '%s' is required", question.Text)
+		}
+		return nil, nil
+	}
+
+	switch question.Type {
+	case QuestionScale:
+		if _, parseError := Scales[question.Scale].ParseValue(answers[0]); parseError != nil || len(answers) > 1 {
+			return nil, fmt.Errorf("answer to '%s' is not on the scale", question.Text)
+		}
+	case QuestionSingleChoice, QuestionMultiChoice:
+		if question.Type == QuestionSingleChoice && len(answers) > 1 {
+			return nil, fmt.Errorf("question '%s' allows only one choice", question.Text)
+		}
+		for _, answer := range answers {
+			if !containsString(question.Choices, answer) {
+				return nil, fmt.Errorf("'%s' is not a choice of '%s'", answer, question.Text)
+			}
+		}
+	case QuestionFreeText:
+		if len(answers) > 1 || len(answers[0]) > maximumTextInput {
+			return nil, fmt.Errorf("answer to '%s' is too long", question.Text)
+		}
+	}
+
+	return answers, nil
+}
+
+func containsString(values []string, value string) bool {
+	for _, candidate := range values {
+		if candidate == value {
+			return true
+		}
+	}
+	return false
+}
+
+func ensureDefaultSurvey(database *storm.DB, clock Clock) error {
+	survey := new(Survey)
+
+	if databaseError := database.One("Key", surveyKey(DefaultSurveyId, 1), survey); databaseError != storm.ErrNotFound {
+		return databaseError
+	}
+
+	defaultSurvey := getDefaultSurvey()
+	defaultSurvey.CreatedAt = clock.Now()
+	return database.Save(&defaultSurvey)
+}
+
+func getSurvey(database storm.Node, id string, version int) (survey *Survey, databaseError error) {
+	survey = new(Survey)
+	databaseError = database.One("Key", surveyKey(id, version), survey)
+	return survey, databaseError
+}
+
+func getLatestSurvey(database *storm.DB, id string) (survey *Survey, databaseError error) {
+	var versions []Survey
+
+	if databaseError = database.Find("Id", id, &versions); databaseError != nil {
+		return nil, databaseError
+	}
+
+	survey = &versions[0]
+	for index := range versions {
+		if versions[index].Version > survey.Version {
+			survey = &versions[index]
+		}
+	}
+
+	return survey, nil
+}
+
+func getLatestSurveys(database *storm.DB) (surveys []Survey, databaseError error) {
+	var versions []Survey
+
+	if databaseError = database.All(&versions); databaseError != nil {
+		return nil, databaseError
+	}
+
+	latest := map[string]int{}
+
+	for _, survey := range versions {
+		if index, found := latest[survey.Id]; !found {
+			latest[survey.Id] = len(surveys)
+			surveys = append(surveys, survey)
+		} else if survey.Version > surveys[index].Version {
+			surveys[index] = survey
+		}
+	}
+
+	return surveys, nil
+}
+
+func getActiveSurvey(database *storm.DB) (survey *Survey, databaseError error) {
+	var id string
+
+	if databaseError = database.Get(settingsBucket, activeSurveyKey, &id); databaseError != nil {
+		id = DefaultSurveyId
+	}
+
+	return getLatestSurvey(database, id)
+}
+
+func getSurveyOfDay(database storm.Node, dailyMoods *DailyMoods) (survey *Survey, databaseError error) {
+	if dailyMoods.SurveyId == "" {
+		survey := getDefaultSurvey()
+		survey.Questions[0].Scale = dailyMoods.Scale.Name
+		return &survey, nil
+	}
+
+	return getSurvey(database, dailyMoods.SurveyId, dailyMoods.SurveyVersion)
+}
+
+func saveSurveyResults(database storm.Node, dailyMoods *DailyMoods, survey *Survey, answers Answers) error {
+	results := new(SurveyResults)
+
+	if databaseError := database.One("DateString", dailyMoods.DateString, results); databaseError == storm.ErrNotFound {
+		results = &SurveyResults{dailyMoods.DateString, survey.Id, survey.Version, map[string]*QuestionAggregate{}}
+	} else if databaseError != nil {
+		return databaseError
+	}
+
+	for _, question := range survey.Questions {
+		if question.Id == MoodQuestionId || len(answers[question.Id]) == 0 {
+			continue
+		}
+
+		aggregate, found := results.Answers[question.Id]
+		if !found {
+			aggregate = &QuestionAggregate{Counts: map[string]int{}}
+			results.Answers[question.Id] = aggregate
+		}
+
+		aggregate.Answered++
+
+		if question.Type == QuestionFreeText {
+			aggregate.Texts = append(aggregate.Texts, answers[question.Id][0])
+		} else {
+			for _, answer := range answers[question.Id] {
+				aggregate.Counts[answer]++
+			}
+		}
+	}
+
+	return database.Save(results)
+}
+
+func recordSurveyAnswers(database *storm.DB, repositories Repositories, feedbackIdentifier *FeedbackIdentifier, values Answers, channel string) error {
+	transaction, databaseError := database.Begin(true)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	defer transaction.Rollback()
+
+	transactional := newStormRepositories(transaction)
+	transactional.Organization, transactional.Clock, transactional.Mailer = repositories.Organization, repositories.Clock, repositories.Mailer
+
+	if feedbackIdentifier = getFeedbackIdentifier(transactional.FeedbackKeys, feedbackIdentifier.Key); feedbackIdentifier == nil {
+		return ErrNotFound
+	}
+
+	dailyMoods, databaseError := getDailyMoodsByDate(transactional.Moods, feedbackIdentifier.DateString)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	survey, databaseError := getSurveyOfDay(transaction, dailyMoods)
+
+	if databaseError != nil {
+		return databaseError
+	}
+
+	answers := Answers{}
+
+	for _, question := range survey.Questions {
+		validAnswers, validationError := question.validateAnswer(values[question.Id])
+
+		if validationError != nil {
+			return &AnswerError{validationError.Error()}
+		}
+
+		answers[question.Id] = validAnswers
+	}
+
+	response := newResponse(feedbackIdentifier, survey, answers, channel, repositories.Clock.Now())
+
+	if databaseError = transaction.Save(&response); databaseError != nil {
+		return databaseError
+	}
+
+	if databaseError = applyResponse(transaction, transactional, dailyMoods, survey, response); databaseError != nil {
+		return databaseError
+	}
+
+	if databaseError = removeFeedbackIdentifier(transactional.FeedbackKeys, feedbackIdentifier); databaseError != nil {
+		return databaseError
+	}
+
+	if databaseError = transaction.Commit(); databaseError != nil {
+		return databaseError
+	}
+
+	publishEvent(database, repositories.Clock, EventVoteRecorded, VoteRecordedEvent{response.DateString, response.SurveyId, response.SurveyVersion, channel})
+	return nil
+}
+
+func (answerError *AnswerError) Error() string {
+	return answerError.Message
+}
+
+func publishSurveyResults(results SurveyResults, withTexts bool) SurveyResults {
+	published := SurveyResults{results.DateString, results.SurveyId, results.SurveyVersion, map[string]*QuestionAggregate{}}
+
+	for id, aggregate := range results.Answers {
+		if isPublishable(aggregate.Answered) {
+			published.Answers[id] = &QuestionAggregate{Answered: aggregate.Answered, Counts: aggregate.Counts}
+
+			if withTexts {
+				published.Answers[id].Texts = shuffleTexts(aggregate.Texts)
+			}
+		} else {
+			published.Answers[id] = &QuestionAggregate{Suppressed: true}
+		}
+	}
+
+	return published
+}
+
+func shuffleTexts(texts []string) []string {
+	if len(texts) == 0 {
+		return nil
+	}
+
+	shuffleLock.Lock()
+	order := shuffleSource.Perm(len(texts))
+	shuffleLock.Unlock()
+
+	shuffled := make([]string, len(texts))
+	for index, position := range order {
+		shuffled[position] = texts[index]
+	}
+	return shuffled
+}
+
+func getSurveyFormHtmlText(key string, survey *Survey, locale string) string {
+	if survey.hasOnlyMoodQuestion() {
+		return getFormHtmlText(key, Scales[survey.Questions[0].Scale], locale)
+	}
+
+	fields := make([]string, 0, len(survey.Questions))
+
+	for _, question := range survey.Questions {
+		fields = append(fields, getQuestionHtmlText(question, locale))
+	}
+
+	return `<html lang="` + locale + `">
+	<head><meta charset="utf-8"></head>
+	<body>
+	<h1>` + html.EscapeString(localizeSurveyText(locale, survey.Name)) + `</h1>
+	<form method="POST" action="` + html.EscapeString(BaseUrl+"/moods/"+key) + `">
+	` + strings.Join(fields, "\n\t") + `
+	<input type="submit" value="` + html.EscapeString(translate(locale, "form.send")) + `">
+	</form>
+	</body>
+	</html>`
+}
+
+func getQuestionHtmlText(question Question, locale string) string {
+	name := html.EscapeString(question.Id)
+	inputs := []string{}
+
+	required := ""
+	if question.Required {
+		required = " required"
+	}
+
+	switch question.Type {
+	case QuestionScale:
+		for _, point := range Scales[question.Scale].Points {
+			inputs = append(inputs, `<label><input type="radio" name="`+name+`" value="`+strconv.Itoa(point.Value)+`"`+required+`> `+html.EscapeString(point.Emoji+" "+localizeScaleLabel(locale, point.Label))+`</label>`)
+		}
+	case QuestionSingleChoice, QuestionMultiChoice:
+		inputType := "radio"
+		if question.Type == QuestionMultiChoice {
+			inputType = "checkbox"
+			required = ""
+		}
+		for _, choice := range question.Choices {
+			inputs = append(inputs, `<label><input type="`+inputType+`" name="`+name+`" value="`+html.EscapeString(choice)+`"`+required+`> `+html.EscapeString(choice)+`</label>`)
+		}
+	case QuestionFreeText:
+		inputs = append(inputs, `<textarea name="`+name+`" maxlength="`+strconv.Itoa(maximumTextInput)+`"`+required+`></textarea>`)
+	}
+
+	return `<fieldset>
+	<legend>` + html.EscapeString(localizeSurveyText(locale, question.Text)) + `</legend>
+	` + strings.Join(inputs, "<br/>\n\t") + `
+	</fieldset>`
+}
+
+func getSurveys(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		surveys, databaseError := getLatestSurveys(database)
+
+		if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, surveys)
+		}
+	})
+}
+
+func getSurveyById(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		var survey *Survey
+		var databaseError error
+
+		if version := context.QueryParam("version"); version != "" {
+			number, _ := strconv.Atoi(version)
+			survey, databaseError = getSurvey(database, id, number)
+		} else {
+			survey, databaseError = getLatestSurvey(database, id)
+		}
+
+		if databaseError == storm.ErrNotFound {
+			return context.String(http.StatusNotFound, "Survey with id '"+id+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusOK, survey)
+		}
+	})
+}
+
+func postSurvey(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		survey := new(Survey)
+
+		if jsonError := context.Bind(survey); jsonError != nil {
+			return jsonError
+		}
+
+		if validationError := survey.validate(); validationError != nil {
+			return context.String(http.StatusBadRequest, validationError.Error())
+		}
+
+		if _, databaseError := getLatestSurvey(database, survey.Id); databaseError == nil {
+			return context.String(http.StatusConflict, "Survey with id '"+survey.Id+"' already exists!")
+		}
+
+		return saveSurveyVersion(database, context, survey, 1, repositories.Clock.Now())
+	})
+}
+
+func putSurvey(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		survey := new(Survey)
+
+		if jsonError := context.Bind(survey); jsonError != nil {
+			return jsonError
+		}
+
+		survey.Id = id
+
+		if validationError := survey.validate(); validationError != nil {
+			return context.String(http.StatusBadRequest, validationError.Error())
+		}
+
+		latest, databaseError := getLatestSurvey(database, id)
+
+		if databaseError == storm.ErrNotFound {
+			return context.String(http.StatusNotFound, "Survey with id '"+id+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		return saveSurveyVersion(database, context, survey, latest.Version+1, repositories.Clock.Now())
+	})
+}
+
+func saveSurveyVersion(database *storm.DB, context echo.Context, survey *Survey, version int, now time.Time) error {
+	survey.Version = version
+	survey.Key = surveyKey(survey.Id, version)
+	survey.CreatedAt = now
+
+	if databaseError := database.Save(survey); databaseError != nil {
+		return databaseError
+	} else {
+		return context.JSON(http.StatusCreated, survey)
+	}
+}
+
+func postActiveSurvey(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+
+		if _, databaseError := getLatestSurvey(database, id); databaseError == storm.ErrNotFound {
+			return context.String(http.StatusNotFound, "Survey with id '"+id+"' not found!")
+		} else if databaseError != nil {
+			return databaseError
+		}
+
+		if databaseError := database.Set(settingsBucket, activeSurveyKey, id); databaseError != nil {
+			return databaseError
+		} else {
+			return context.NoContent(http.StatusNoContent)
+		}
+	})
+}
+
+func getSurveyResults(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		var results []SurveyResults
+
+		if databaseError := database.Find("SurveyId", context.Param("id"), &results); databaseError != nil && databaseError != storm.ErrNotFound {
+			return databaseError
+		}
+
+		published := make([]SurveyResults, 0, len(results))
+		for _, result := range results {
+			published = append(published, publishSurveyResults(result, false))
+		}
+
+		return context.JSON(http.StatusOK, published)
+	})
+}
@@ -0,0 +1,19 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestPublishedSurveyResultsLeaveOutTexts(t *testing.T) {
+	aggregate := &QuestionAggregate{Answered: MinimumResponses, Texts: make([]string, MinimumResponses)}
+	results := SurveyResults{"01-06-2026", DefaultSurveyId, 1, map[string]*QuestionAggregate{"comment": aggregate}}
+
+	public := publishSurveyResults(results, false)
+	expect(t, public.Answers["comment"].Texts == nil, "free-text answers must not be published")
+
+	admin := publishSurveyResults(results, true)
+	expect(t, len(admin.Answers["comment"].Texts) == MinimumResponses, "admins must see every free-text answer")
+
+	aggregate.Answered = MinimumResponses - 1
+	expect(t, publishSurveyResults(results, true).Answers["comment"].Suppressed, "answers below the threshold must be suppressed for admins too")
+}
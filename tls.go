@@ -0,0 +1,183 @@
+package main
+
+import (
+	"crypto/tls"
+	"github.com/labstack/echo"
+	"github.com/labstack/echo/engine"
+	"github.com/labstack/echo/engine/fasthttp"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+)
+
+type CertificateReloader struct {
+	lock        sync.RWMutex
+	certFile    string
+	keyFile     string
+	certificate *tls.Certificate
+	modified    time.Time
+}
+
+var TLSCertFile string = os.Getenv("MUT_TLS_CERT")
+var TLSKeyFile string = os.Getenv("MUT_TLS_KEY")
+var RedirectBind string = os.Getenv("MUT_HTTP_REDIRECT_BIND")
+var BaseUrl string = getEnvString("MUT_BASE_URL", getDefaultBaseUrl())
+
+func isTLSEnabled() bool {
+	return TLSCertFile != "" && TLSKeyFile != ""
+}
+
+func getDefaultBaseUrl() string {
+	if isTLSEnabled() {
+		return "https://mut-musca.rhcloud.com"
+	} else {
+		return "http://mut-musca.rhcloud.com"
+	}
+}
+
+func newCertificateReloader(certFile string, keyFile string) (reloader *CertificateReloader, reloadError error) {
+	reloader = &CertificateReloader{certFile: certFile, keyFile: keyFile}
+
+	if reloadError = reloader.Reload(); reloadError != nil {
+		return nil, reloadError
+	}
+
+	return reloader, nil
+}
+
+func (reloader *CertificateReloader) Reload() error {
+	certificate, loadError := tls.LoadX509KeyPair(reloader.certFile, reloader.keyFile)
+
+	if loadError != nil {
+		return loadError
+	}
+
+	reloader.lock.Lock()
+	defer reloader.lock.Unlock()
+
+	reloader.certificate = &certificate
+	reloader.modified = reloader.lastModified()
+	return nil
+}
+
+func (reloader *CertificateReloader) GetCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	reloader.lock.RLock()
+	defer reloader.lock.RUnlock()
+
+	return reloader.certificate, nil
+}
+
+func (reloader *CertificateReloader) lastModified() (modified time.Time) {
+	for _, path := range []string{reloader.certFile, reloader.keyFile} {
+		if info, statError := os.Stat(path); statError == nil && info.ModTime().After(modified) {
+			modified = info.ModTime()
+		}
+	}
+	return modified
+}
+
+func (reloader *CertificateReloader) hasChanged() bool {
+	reloader.lock.RLock()
+	defer reloader.lock.RUnlock()
+
+	return reloader.lastModified().After(reloader.modified)
+}
+
+func (reloader *CertificateReloader) Watch(interval time.Duration) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	ticker := time.NewTicker(interval)
+
+	for {
+		select {
+		case <-hangups:
+			log.Println("Received SIGHUP, reloading certificate.")
+		case <-ticker.C:
+			if !reloader.hasChanged() {
+				continue
+			}
+			log.Println("Certificate files changed, reloading certificate.")
+		}
+
+		if reloadError := reloader.Reload(); reloadError != nil {
+			log.Printf("Keeping previous certificate: %s", reloadError)
+		}
+	}
+}
+
+func createEngine(bind string) engine.Server {
+	if !isTLSEnabled() {
+		return fasthttp.New(bind)
+	}
+
+	reloader, reloadError := newCertificateReloader(TLSCertFile, TLSKeyFile)
+
+	if reloadError != nil {
+		log.Fatal(reloadError)
+	}
+
+	go reloader.Watch(30 * time.Second)
+
+	listener, listenError := net.Listen("tcp", bind)
+
+	if listenError != nil {
+		log.Fatal(listenError)
+	}
+
+	if RedirectBind != "" {
+		go serveHttpsRedirect(RedirectBind)
+	}
+
+	config := &tls.Config{GetCertificate: reloader.GetCertificate, MinVersion: tls.VersionTLS12}
+	return fasthttp.WithConfig(engine.Config{Address: bind, Listener: tls.NewListener(listener, config)})
+}
+
+func serveHttpsRedirect(bind string) {
+	base, parseError := url.Parse(BaseUrl)
+
+	if parseError != nil {
+		log.Fatal(parseError)
+	}
+
+	log.Println("Redirecting HTTP on bind " + bind + " to " + base.Scheme + "://" + base.Host + ".")
+
+	redirectError := http.ListenAndServe(bind, http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		http.Redirect(writer, request, "https://"+base.Host+request.URL.RequestURI(), http.StatusMovedPermanently)
+	}))
+
+	log.Fatal(redirectError)
+}
+
+func securityHeaders() echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(context echo.Context) error {
+			header := context.Response().Header()
+
+			if isTLSEnabled() {
+				header.Set("Strict-Transport-Security", "max-age=31536000; includeSubDomains")
+			}
+			header.Set("X-Frame-Options", "DENY")
+			header.Set("X-Content-Type-Options", "nosniff")
+			header.Set("Referrer-Policy", "no-referrer")
+
+			return next(context)
+		}
+	}
+}
+
+func formSecurityPolicy() echo.MiddlewareFunc {
+	policy := "default-src 'none'; form-action 'self' " + BaseUrl + "; frame-ancestors 'none'; base-uri 'none'"
+
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(context echo.Context) error {
+			context.Response().Header().Set("Content-Security-Policy", policy)
+			return next(context)
+		}
+	}
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"github.com/asdine/storm"
+	"github.com/asdine/storm/q"
+	"github.com/labstack/echo"
+	"github.com/nu7hatch/gouuid"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	EventSubscriberCreated      = "subscriber.created"
+	EventSubscriberUnsubscribed = "subscriber.unsubscribed"
+	EventSurveySent             = "survey.sent"
+	EventVoteRecorded           = "vote.recorded"
+	EventDayClosed              = "day.closed"
+	EventAll                    = "*"
+
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+)
+
+type (
+	Webhook struct {
+		Id        string    `json:"id" storm:"id"`
+		Url       string    `json:"url"`
+		Events    []string  `json:"events"`
+		Secret    string    `json:"secret,omitempty"`
+		CreatedAt time.Time `json:"created-at"`
+	}
+
+	WebhookEvent struct {
+		Id         string      `json:"id"`
+		Type       string      `json:"type"`
+		OccurredAt time.Time   `json:"occurred-at"`
+		Data       interface{} `json:"data"`
+	}
+
+	WebhookDelivery struct {
+		Id            string    `json:"id" storm:"id"`
+		WebhookId     string    `json:"webhook" storm:"index"`
+		EventId       string    `json:"event"`
+		EventType     string    `json:"event-type"`
+		Payload       string    `json:"payload"`
+		Status        string    `json:"status" storm:"index"`
+		Attempts      int       `json:"attempts"`
+		StatusCode    int       `json:"status-code,omitempty"`
+		Error         string    `json:"error,omitempty"`
+		CreatedAt     time.Time `json:"created-at"`
+		NextAttemptAt time.Time `json:"next-attempt-at,omitempty"`
+		DeliveredAt   time.Time `json:"delivered-at,omitempty"`
+	}
+
+	SubscriberEvent struct {
+		Uuid string `json:"uuid"`
+		Team string `json:"team,omitempty"`
+	}
+
+	SurveySentEvent struct {
+		DateString    string `json:"date"`
+		SurveyId      string `json:"survey"`
+		SurveyVersion int    `json:"survey-version"`
+		Invited       int    `json:"invited"`
+		Paused        int    `json:"paused"`
+	}
+
+	VoteRecordedEvent struct {
+		DateString    string `json:"date"`
+		SurveyId      string `json:"survey"`
+		SurveyVersion int    `json:"survey-version"`
+		Channel       string `json:"channel"`
+	}
+
+	DayClosedEvent struct {
+		DateString string `json:"date"`
+		Invited    int    `json:"invited"`
+		Paused     int    `json:"paused"`
+		Responses  int    `json:"responses"`
+	}
+)
+
+var WebhookSchedule string = getEnvString("MUT_WEBHOOK_SCHEDULE", "*/30 * * * * *")
+var WebhookMaxAttempts int = getEnvInt("MUT_WEBHOOK_MAX_ATTEMPTS", 6)
+var WebhookRetrySeconds int = getEnvInt("MUT_WEBHOOK_RETRY_SECONDS", 60)
+var WebhookBatchSize int = getEnvInt("MUT_WEBHOOK_BATCH_SIZE", 100)
+var WebhookTimeoutSeconds int = getEnvInt("MUT_WEBHOOK_TIMEOUT_SECONDS", 10)
+
+var webhookClient = &http.Client{Timeout: time.Duration(WebhookTimeoutSeconds) * time.Second}
+
+var webhookEvents = []string{EventSubscriberCreated, EventSubscriberUnsubscribed, EventSurveySent, EventVoteRecorded, EventDayClosed}
+
+func (webhook *Webhook) validate() error {
+	if address, parseError := url.Parse(webhook.Url); parseError != nil || (address.Scheme != "http" && address.Scheme != "https") || address.Host == "" {
+		return fmt.Errorf("webhook url '%s' must be an absolute http or https url", webhook.Url)
+	}
+
+	if len(webhook.Events) == 0 {
+		return fmt.Errorf("webhook needs at least one event")
+	}
+
+	for _, event := range webhook.Events {
+		if event != EventAll && !containsString(webhookEvents, event) {
+			return fmt.Errorf("unknown event '%s'", event)
+		}
+	}
+
+	return nil
+}
+
+func (webhook *Webhook) accepts(eventType string) bool {
+	return containsString(webhook.Events, EventAll) || containsString(webhook.Events, eventType)
+}
+
+func createWebhookSecret() string {
+	secret := make([]byte, 32)
+	_, _ = rand.Read(secret)
+	return hex.EncodeToString(secret)
+}
+
+func signWebhook(secret string, timestamp string, payload string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "." + payload))
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func publishEvent(database *storm.DB, clock Clock, eventType string, data interface{}) {
+	id, _ := uuid.NewV4()
+	event := WebhookEvent{id.String(), eventType, clock.Now(), data}
+	getEventHub(database).Broadcast(event)
+
+	var webhooks []Webhook
+
+	if databaseError := database.All(&webhooks); databaseError != nil {
+		log.Printf("%s", databaseError)
+		return
+	}
+
+	payload, jsonError := json.Marshal(event)
+
+	if jsonError != nil {
+		log.Printf("%s", jsonError)
+		return
+	}
+
+	for _, webhook := range webhooks {
+		if !webhook.accepts(eventType) {
+			continue
+		}
+
+		deliveryId, _ := uuid.NewV4()
+		delivery := WebhookDelivery{
+			Id:            deliveryId.String(),
+			WebhookId:     webhook.Id,
+			EventId:       event.Id,
+			EventType:     eventType,
+			Payload:       string(payload),
+			Status:        DeliveryPending,
+			CreatedAt:     event.OccurredAt,
+			NextAttemptAt: event.OccurredAt,
+		}
+
+		if databaseError := database.Save(&delivery); databaseError != nil {
+			log.Printf("%s", databaseError)
+		}
+	}
+}
+
+func deliverWebhooks(database *storm.DB, repositories Repositories) func() {
+	var running int32
+
+	return func() {
+		if !atomic.CompareAndSwapInt32(&running, 0, 1) {
+			return
+		}
+		defer atomic.StoreInt32(&running, 0)
+
+		var deliveries []WebhookDelivery
+		databaseError := database.Select(q.Eq("Status", DeliveryPending), q.Lte("NextAttemptAt", repositories.Clock.Now())).Limit(WebhookBatchSize).Find(&deliveries)
+
+		if databaseError != nil && databaseError != storm.ErrNotFound {
+			log.Printf("%s", databaseError)
+			return
+		}
+
+		for index := range deliveries {
+			webhook := new(Webhook)
+
+			if databaseError = database.One("Id", deliveries[index].WebhookId, webhook); databaseError != nil {
+				deliveries[index].Status = DeliveryFailed
+				deliveries[index].Error = "webhook was deleted"
+			} else {
+				attemptDelivery(webhook, &deliveries[index], repositories.Clock.Now())
+			}
+
+			if databaseError = database.Save(&deliveries[index]); databaseError != nil {
+				log.Printf("%s", databaseError)
+			}
+		}
+	}
+}
+
+func postSignedWebhook(url string, secret string, eventType string, id string, payload string, now time.Time) (*http.Response, error) {
+	request, requestError := http.NewRequest("POST", url, bytes.NewReader([]byte(payload)))
+
+	if requestError != nil {
+		return nil, requestError
+	}
+
+	timestamp := strconv.FormatInt(now.Unix(), 10)
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Mut-Event", eventType)
+	request.Header.Set("X-Mut-Delivery", id)
+	request.Header.Set("X-Mut-Timestamp", timestamp)
+	request.Header.Set("X-Mut-Signature", signWebhook(secret, timestamp, payload))
+
+	return webhookClient.Do(request)
+}
+
+func attemptDelivery(webhook *Webhook, delivery *WebhookDelivery, now time.Time) {
+	delivery.Attempts++
+	delivery.StatusCode = 0
+	delivery.Error = ""
+
+	response, responseError := postSignedWebhook(webhook.Url, webhook.Secret, delivery.EventType, delivery.Id, delivery.Payload, now)
+
+	if responseError != nil {
+		delivery.Error = responseError.Error()
+	} else {
+		delivery.StatusCode = response.StatusCode
+		body, _ := ioutil.ReadAll(response.Body)
+		response.Body.Close()
+
+		if response.StatusCode < 300 {
+			delivery.Status = DeliveryDelivered
+			delivery.DeliveredAt = now
+			return
+		}
+		delivery.Error = truncate(string(body), 200)
+	}
+
+	if delivery.Attempts >= WebhookMaxAttempts {
+		delivery.Status = DeliveryFailed
+		log.Printf("Giving up on %s delivery %s to %s after %d attempts.", delivery.EventType, delivery.Id, webhook.Url, delivery.Attempts)
+		return
+	}
+
+	delivery.NextAttemptAt = now.Add(time.Duration(WebhookRetrySeconds<<uint(delivery.Attempts-1)) * time.Second)
+}
+
+func truncate(text string, length int) string {
+	if len(text) > length {
+		return text[:length]
+	}
+	return text
+}
+
+func getWebhooks(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		var webhooks []Webhook
+
+		if databaseError := database.All(&webhooks); databaseError != nil {
+			return databaseError
+		}
+
+		for index := range webhooks {
+			webhooks[index].Secret = ""
+		}
+
+		return context.JSON(http.StatusOK, webhooks)
+	})
+}
+
+func postWebhook(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		webhook := new(Webhook)
+
+		if jsonError := context.Bind(webhook); jsonError != nil {
+			return jsonError
+		}
+
+		if validationError := webhook.validate(); validationError != nil {
+			return context.String(http.StatusBadRequest, validationError.Error())
+		}
+
+		id, _ := uuid.NewV4()
+		webhook.Id = id.String()
+		webhook.CreatedAt = repositories.Clock.Now()
+
+		if webhook.Secret == "" {
+			webhook.Secret = createWebhookSecret()
+		}
+
+		if databaseError := database.Save(webhook); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusCreated, webhook)
+		}
+	})
+}
+
+func deleteWebhook(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		webhook := new(Webhook)
+
+		if databaseError := database.One("Id", id, webhook); databaseError != nil {
+			return context.String(http.StatusNotFound, "Webhook with id '"+id+"' not found!")
+		}
+
+		if databaseError := database.Remove(webhook); databaseError != nil {
+			return databaseError
+		}
+
+		return context.NoContent(http.StatusNoContent)
+	})
+}
+
+func getWebhookDeliveries(database *storm.DB) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("id")
+		deliveries := []WebhookDelivery{}
+
+		if databaseError := database.One("Id", id, new(Webhook)); databaseError != nil {
+			return context.String(http.StatusNotFound, "Webhook with id '"+id+"' not found!")
+		}
+
+		if databaseError := database.Find("WebhookId", id, &deliveries); databaseError != nil && databaseError != storm.ErrNotFound {
+			return databaseError
+		}
+
+		return context.JSON(http.StatusOK, deliveries)
+	})
+}
+
+func postRedelivery(database *storm.DB, repositories Repositories) echo.HandlerFunc {
+	return (func(context echo.Context) error {
+		id := context.Param("delivery")
+		delivery := new(WebhookDelivery)
+
+		if databaseError := database.One("Id", id, delivery); databaseError != nil || delivery.WebhookId != context.Param("id") {
+			return context.String(http.StatusNotFound, "Delivery with id '"+id+"' not found!")
+		}
+
+		delivery.Status = DeliveryPending
+		delivery.Attempts = 0
+		delivery.NextAttemptAt = repositories.Clock.Now()
+
+		if databaseError := database.Save(delivery); databaseError != nil {
+			return databaseError
+		} else {
+			return context.JSON(http.StatusAccepted, delivery)
+		}
+	})
+}